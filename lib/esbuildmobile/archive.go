@@ -0,0 +1,141 @@
+package esbuildmobile
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// NewVirtualFSFromArchive creates a file system holding every file of a zip,
+// tar or gzip-compressed tar archive
+func NewVirtualFSFromArchive(data []byte) (*VirtualFS, error) {
+	fs := NewVirtualFS()
+	if err := fs.AddArchive(data); err != nil {
+		return nil, err
+	}
+	return fs, nil
+}
+
+// AddArchive adds every file and directory of a zip, tar or gzip-compressed
+// tar archive. The format is detected from the contents. Paths inside the
+// archive become absolute paths, so "src/index.js" is added as "/src/index.js".
+// Symbolic links and other special entries are skipped.
+func (fs *VirtualFS) AddArchive(data []byte) error {
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")), bytes.HasPrefix(data, []byte("PK\x05\x06")):
+		return fs.addZip(data)
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		return fs.addTar(reader)
+	default:
+		return fs.addTar(bytes.NewReader(data))
+	}
+}
+
+func (fs *VirtualFS) addZip(data []byte) error {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() {
+			fs.AddDirectory(file.Name)
+			continue
+		}
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		contents, err := readZipFile(file)
+		if err != nil {
+			return fmt.Errorf("could not read %q from the archive: %w", file.Name, err)
+		}
+		fs.AddFile(file.Name, contents)
+	}
+	return nil
+}
+
+func readZipFile(file *zip.File) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+func (fs *VirtualFS) addTar(data io.Reader) error {
+	reader := tar.NewReader(data)
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			fs.AddDirectory(header.Name)
+		case tar.TypeReg:
+			contents, err := io.ReadAll(reader)
+			if err != nil {
+				return fmt.Errorf("could not read %q from the archive: %w", header.Name, err)
+			}
+			fs.AddFile(header.Name, contents)
+		}
+	}
+}
+
+// ConfigureArchive bundles from a zip, tar or gzip-compressed tar archive
+// instead of the disk. The archive is added to the VirtualFS (creating one if
+// needed) and the entry path inside the archive becomes an entry point.
+// Nothing is added if the archive cannot be read or has no such entry.
+// Documentation: https://esbuild.github.io/api/#entry-points
+func (b *BuildOptions) ConfigureArchive(data []byte, entryPath string) error {
+	archive, err := NewVirtualFSFromArchive(data)
+	if err != nil {
+		return err
+	}
+
+	extensions := b.ResolveExtensions
+	if len(extensions) == 0 {
+		extensions = defaultResolveExtensions
+	}
+	entry, ok := archive.resolveFile(cleanVirtualPath(entryPath), extensions)
+	if !ok {
+		return fmt.Errorf("entry point %q is not in the archive", entryPath)
+	}
+
+	if b.VirtualFS == nil {
+		b.VirtualFS = archive
+	} else {
+		b.VirtualFS.merge(archive)
+	}
+	b.EntryPoints = append(b.EntryPoints, entry)
+	return nil
+}
+
+// merge adds every file and directory of other, replacing existing files.
+// other must not be used afterwards, since its contents are shared.
+func (fs *VirtualFS) merge(other *VirtualFS) {
+	other.mutex.RLock()
+	defer other.mutex.RUnlock()
+
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	for filePath, contents := range other.files {
+		fs.files[filePath] = contents
+	}
+	for dirPath := range other.dirs {
+		fs.dirs[dirPath] = true
+	}
+}
@@ -0,0 +1,76 @@
+package esbuildmobile
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+)
+
+func TestArchiveBundle(t *testing.T) {
+	files := map[string]string{
+		"app/src/index.js":     `import { value } from "./lib/value"; console.log(value)`,
+		"app/src/lib/value.js": `export { value } from "../../shared"`,
+		"app/shared/index.js":  `export const value = "from archive"`,
+	}
+
+	var zipData bytes.Buffer
+	zipWriter := zip.NewWriter(&zipData)
+	for name, contents := range files {
+		writer, err := zipWriter.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writer.Write([]byte(contents))
+	}
+	zipWriter.Close()
+
+	var tarData bytes.Buffer
+	gzipWriter := gzip.NewWriter(&tarData)
+	tarWriter := tar.NewWriter(gzipWriter)
+	tarWriter.WriteHeader(&tar.Header{Name: "./app/", Typeflag: tar.TypeDir, Mode: 0o755})
+	for name, contents := range files {
+		tarWriter.WriteHeader(&tar.Header{Name: "./" + name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(contents))})
+		tarWriter.Write([]byte(contents))
+	}
+	tarWriter.Close()
+	gzipWriter.Close()
+
+	for format, data := range map[string][]byte{"zip": zipData.Bytes(), "tar.gz": tarData.Bytes()} {
+		options := NewBuildOptions()
+		options.ConfigureBundle(true)
+		if err := options.ConfigureArchive(data, "app/src/index"); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		code, err := Build("", options)
+		if err != nil {
+			t.Fatalf("%s: build failed: %v", format, err)
+		}
+		if !strings.Contains(code, `"from archive"`) {
+			t.Fatalf("%s: unexpected output:\n%s", format, code)
+		}
+	}
+
+	// A failed archive leaves the existing VirtualFS untouched
+	options := NewBuildOptions()
+	options.ConfigureVirtualFS(NewVirtualFS())
+	options.VirtualFS.AddFile("/existing.js", []byte("export {}"))
+	if err := options.ConfigureArchive(zipData.Bytes(), "missing.js"); err == nil {
+		t.Fatal("expected an error for a missing entry point")
+	}
+	if err := options.ConfigureArchive(zipData.Bytes()[:len(zipData.Bytes())/2], "app/src/index"); err == nil {
+		t.Fatal("expected an error for a truncated archive")
+	}
+	if options.VirtualFS.GetFilesCount() != 1 || len(options.EntryPoints) != 0 {
+		t.Fatalf("expected no archive files to be added, got %d files", options.VirtualFS.GetFilesCount())
+	}
+
+	if err := options.ConfigureArchive(tarData.Bytes(), "app/src/index"); err != nil {
+		t.Fatal(err)
+	}
+	if !options.VirtualFS.HasFile("/existing.js") || !options.VirtualFS.HasFile("/app/shared/index.js") {
+		t.Fatal("expected the archive to be merged into the existing VirtualFS")
+	}
+}
@@ -0,0 +1,171 @@
+package esbuildmobile
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// TransformBatch is a list of inputs that are transformed together with
+// TransformJSXBatch, crossing the mobile bridge only once
+type TransformBatch struct {
+	Inputs         []*TransformInput
+	MaxConcurrency int // 0 uses one goroutine per CPU
+}
+
+// TransformInput is a single input of a TransformBatch. Sourcefile and
+// Loader override the shared TransformOptions when set.
+type TransformInput struct {
+	Code       string
+	Sourcefile string
+	Loader     api.Loader
+}
+
+// TransformBatchResult holds one result per input, in input order
+type TransformBatchResult struct {
+	Results []*TransformResult
+}
+
+// NewTransformBatch creates an empty batch
+func NewTransformBatch() *TransformBatch {
+	return &TransformBatch{Inputs: make([]*TransformInput, 0)}
+}
+
+// AddInput adds an input to the batch. A loader of 0 uses the shared options.
+func (b *TransformBatch) AddInput(code string, sourcefile string, loader int) {
+	b.Inputs = append(b.Inputs, &TransformInput{
+		Code:       code,
+		Sourcefile: sourcefile,
+		Loader:     api.Loader(loader),
+	})
+}
+
+func (b *TransformBatch) ConfigureMaxConcurrency(n int) { b.MaxConcurrency = n }
+func (b *TransformBatch) GetInputsCount() int           { return len(b.Inputs) }
+
+func (b *TransformBatch) GetInput(index int) *TransformInput {
+	if index >= 0 && index < len(b.Inputs) {
+		return b.Inputs[index]
+	}
+	return nil
+}
+
+// TransformJSXBatch transforms every input of the batch on a bounded pool of
+// goroutines. Diagnostics are reported per input on each TransformResult, so
+// a failing input does not fail the batch. CancelHandle and TimeoutMillis
+// apply to the batch as a whole: it fails with ErrBuildCancelled or
+// ErrBuildTimeout if it is not done in time, and no more inputs are started.
+// A nil batch has no results.
+// `TransformOptions` is optional
+func TransformJSXBatch(batch *TransformBatch, options *TransformOptions) (*TransformBatchResult, error) {
+	if options == nil {
+		options = &TransformOptions{}
+	}
+	if batch == nil {
+		return &TransformBatchResult{Results: make([]*TransformResult, 0)}, nil
+	}
+
+	// Inputs share one handle, which is cancelled when the batch is cut short
+	batchHandle := NewCancelHandle()
+	shared := *options
+	shared.CancelHandle = batchHandle
+	shared.TimeoutMillis = 0
+
+	done := make(chan []*TransformResult, 1)
+	go func() { done <- transformBatchInputs(batch, &shared, batchHandle) }()
+
+	results, err := waitCancellable(done, options.CancelHandle, options.TimeoutMillis)
+	if err != nil {
+		batchHandle.Cancel()
+		return nil, err
+	}
+	return &TransformBatchResult{Results: results}, nil
+}
+
+// transformBatchInputs transforms the inputs on a bounded pool of
+// goroutines, starting no more inputs once handle is cancelled
+func transformBatchInputs(batch *TransformBatch, options *TransformOptions, handle *CancelHandle) []*TransformResult {
+	workers := batch.MaxConcurrency
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(batch.Inputs) {
+		workers = len(batch.Inputs)
+	}
+
+	results := make([]*TransformResult, len(batch.Inputs))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i], _ = transformBatchInput(batch.Inputs[i], options)
+			}
+		}()
+	}
+feed:
+	for i := range batch.Inputs {
+		select {
+		case indexes <- i:
+		case <-handle.Done():
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
+
+// transformBatchInput transforms one input with a copy of the shared options
+func transformBatchInput(input *TransformInput, options *TransformOptions) (*TransformResult, error) {
+	itemOptions := *options
+	if input.Sourcefile != "" {
+		itemOptions.Sourcefile = input.Sourcefile
+	}
+	if input.Loader != api.LoaderNone {
+		itemOptions.Loader = input.Loader
+	}
+	return TransformJSXWithResult(input.Code, &itemOptions)
+}
+
+// Getter methods for TransformInput
+
+func (i *TransformInput) GetCode() string       { return i.Code }
+func (i *TransformInput) GetSourcefile() string { return i.Sourcefile }
+func (i *TransformInput) GetLoader() int        { return int(i.Loader) }
+
+// Getter methods for TransformBatchResult
+
+func (r *TransformBatchResult) GetResultsCount() int { return len(r.Results) }
+
+func (r *TransformBatchResult) GetResult(index int) *TransformResult {
+	if index >= 0 && index < len(r.Results) {
+		return r.Results[index]
+	}
+	return nil
+}
+
+// HasErrors reports whether any input failed to transform
+func (r *TransformBatchResult) HasErrors() bool {
+	for _, result := range r.Results {
+		if result.HasErrors() {
+			return true
+		}
+	}
+	return false
+}
+
+// GetFailedCount returns the number of inputs that failed to transform
+func (r *TransformBatchResult) GetFailedCount() int {
+	count := 0
+	for _, result := range r.Results {
+		if result.HasErrors() {
+			count++
+		}
+	}
+	return count
+}
@@ -1,6 +1,11 @@
 package esbuildmobile
 
-import "github.com/evanw/esbuild/pkg/api"
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
 
 type BuildOptions struct {
 	// Logging options
@@ -89,7 +94,23 @@ type BuildOptions struct {
 	Stdin          *api.StdinOptions // Documentation: https://esbuild.github.io/api/#stdin
 	Write          bool              // Documentation: https://esbuild.github.io/api/#write
 	AllowOverwrite bool              // Documentation: https://esbuild.github.io/api/#allow-overwrite
-	Plugins        []*Plugin         // Documentation: https://esbuild.github.io/plugins/
+	Plugins        []*Plugin         `json:"-"` // Documentation: https://esbuild.github.io/plugins/
+
+	// Cancellation options
+	CancelHandle  *CancelHandle `json:"-"` // Cancels the build when its Cancel method is called
+	TimeoutMillis int           `json:"-"` // Cancels the build after this many milliseconds, 0 for no timeout
+
+	// Caching options
+	Cache *Cache `json:"-"` // Reuses the result of an earlier build with the same input, options and files
+
+	// In-memory inputs
+	VirtualFS    *VirtualFS    `json:"-"` // Resolves and loads files from memory instead of the disk
+	PackageStore *PackageStore `json:"-"` // Resolves and loads npm packages from in-memory tarballs
+	ImportMap    *ImportMap    // Remaps import specifiers, see https://github.com/WICG/import-maps
+	URLImports   *URLImports   `json:"-"` // Resolves and loads http:// and https:// imports
+
+	// Output options
+	OutputSink OutputSink `json:"-"` // Receives every output file instead of the disk
 
 	// Legacy properties for backwards compatibility
 	Sourcefile   string     // For single file transforms
@@ -114,6 +135,10 @@ func NewBuildOptions() *BuildOptions {
 
 // Convert to esbuild API BuildOptions
 func (b *BuildOptions) ToAPIBuildOptions() api.BuildOptions {
+	return b.toAPIBuildOptions(newPluginHost())
+}
+
+func (b *BuildOptions) toAPIBuildOptions(host *pluginHost) api.BuildOptions {
 	return api.BuildOptions{
 		Color:       b.Color,
 		LogLevel:    b.LogLevel,
@@ -191,24 +216,153 @@ func (b *BuildOptions) ToAPIBuildOptions() api.BuildOptions {
 		Stdin:          b.Stdin,
 		Write:          b.Write,
 		AllowOverwrite: b.AllowOverwrite,
-		Plugins:        b.convertPlugins(),
+		Plugins:        b.convertPlugins(host),
+	}
+}
+
+// toAPIBuildOptionsWithInput converts to esbuild API BuildOptions, using input
+// as stdin when it is not empty. Write is forced to false when an OutputSink
+// is set, since the sink writes the output files instead, and unless
+// AllowOverwrite is set the metafile is enabled so that the sink can tell
+// the input files apart. Results are passed through resultFromAPI to drop a
+// metafile that was not asked for. The plugins are set up on host, which
+// the caller disposes when the build is over.
+func (b *BuildOptions) toAPIBuildOptionsWithInput(input string, host *pluginHost) api.BuildOptions {
+	buildOpts := b.toAPIBuildOptions(host)
+
+	// Set up stdin if input is provided
+	if input != "" {
+		loader := b.LoaderSingle
+		if loader == 0 {
+			loader = api.LoaderJS
+		}
+
+		buildOpts.Stdin = &api.StdinOptions{
+			Contents:   input,
+			Sourcefile: b.Sourcefile,
+			Loader:     loader,
+		}
+	}
+
+	if b.OutputSink != nil {
+		buildOpts.Write = false
+		if !b.AllowOverwrite {
+			buildOpts.Metafile = true
+		}
+	}
+
+	return buildOpts
+}
+
+// resultFromAPI converts a result of a build with these options, dropping
+// the metafile unless Metafile is set
+func (b *BuildOptions) resultFromAPI(result *api.BuildResult) *BuildResult {
+	buildResult := buildResultFromAPI(result)
+	if !b.Metafile {
+		buildResult.Metafile = ""
+	}
+	return buildResult
+}
+
+// allPlugins returns the user's plugins followed by the built-in plugins
+// that implement options such as VirtualFS. The cache keys of the built-in
+// plugins hash everything they serve, so they are only computed when
+// withCacheKeys is set, for Cache.key.
+func (b *BuildOptions) allPlugins(withCacheKeys bool) []*Plugin {
+	plugins := append([]*Plugin(nil), b.Plugins...)
+	if b.VirtualFS != nil {
+		plugins = append(plugins, b.VirtualFS.plugin(b, withCacheKeys))
 	}
+	if b.PackageStore != nil {
+		plugins = append(plugins, b.PackageStore.plugin(b, withCacheKeys))
+	}
+	if b.URLImports != nil {
+		plugins = append(plugins, b.URLImports.plugin(b, withCacheKeys))
+	}
+	return plugins
 }
 
-// convertPlugins converts our mobile-friendly plugins to esbuild API plugins
-func (b *BuildOptions) convertPlugins() []api.Plugin {
-	apiPlugins := make([]api.Plugin, len(b.Plugins))
-	for i, plugin := range b.Plugins {
-		apiPlugins[i] = b.convertPlugin(plugin)
+// convertPlugins converts our mobile-friendly plugins to esbuild API plugins.
+// The import map comes first so that it applies to every other plugin, and
+// the output sink comes last so that it writes the final output files.
+func (b *BuildOptions) convertPlugins(host *pluginHost) []api.Plugin {
+	apiPlugins := make([]api.Plugin, 0, len(b.Plugins)+6)
+	if b.ImportMap != nil {
+		apiPlugins = append(apiPlugins, b.ImportMap.apiPlugin())
+	}
+	for _, plugin := range b.allPlugins(false) {
+		apiPlugins = append(apiPlugins, b.convertPlugin(plugin, host))
+	}
+	if b.OutputSink != nil {
+		apiPlugins = append(apiPlugins, b.outputSinkPlugin())
+	}
+	return append(apiPlugins, host.apiPlugin())
+}
+
+// pluginHost holds the state shared by the plugins of one build or
+// BuildContext: the registry of their PluginData, and the plugins that were
+// set up, whose OnDispose callbacks it runs.
+type pluginHost struct {
+	registry *pluginDataRegistry
+
+	mutex       sync.Mutex
+	plugins     []*Plugin
+	disposeOnce sync.Once
+}
+
+func newPluginHost() *pluginHost {
+	return &pluginHost{registry: newPluginDataRegistry()}
+}
+
+func (h *pluginHost) setUp(plugin *Plugin) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.plugins = append(h.plugins, plugin)
+}
+
+// dispose runs the OnDispose callbacks of every plugin that was set up, in
+// order. Only the first call runs them, and every call waits until they are done.
+func (h *pluginHost) dispose() {
+	h.disposeOnce.Do(func() {
+		h.mutex.Lock()
+		plugins := h.plugins
+		h.mutex.Unlock()
+
+		for _, plugin := range plugins {
+			for _, callback := range plugin.onDisposeCallbacks {
+				callback.Call()
+			}
+		}
+		h.registry.clear()
+	})
+}
+
+// apiPlugin clears the PluginData registry when a build ends. It is
+// registered after every other plugin so that no callback of the build can
+// still need it. esbuild's OnDispose only matters for callers of
+// ToAPIBuildOptions, since every build started here disposes the host itself.
+func (h *pluginHost) apiPlugin() api.Plugin {
+	return api.Plugin{
+		Name: "esbuildmobile-plugin-host",
+		Setup: func(build api.PluginBuild) {
+			build.OnEnd(func(result *api.BuildResult) (api.OnEndResult, error) {
+				h.registry.clear()
+				return api.OnEndResult{}, nil
+			})
+			build.OnDispose(h.dispose)
+		},
 	}
-	return apiPlugins
 }
 
 // convertPlugin converts a single plugin to esbuild API plugin
-func (b *BuildOptions) convertPlugin(plugin *Plugin) api.Plugin {
+func (b *BuildOptions) convertPlugin(plugin *Plugin, host *pluginHost) api.Plugin {
 	return api.Plugin{
 		Name: plugin.GetName(),
 		Setup: func(build api.PluginBuild) {
+			host.setUp(plugin)
+			registry := host.registry
+			resolver := &Resolver{build: build, registry: registry, pluginName: plugin.GetName()}
+
 			// Register onResolve callbacks
 			for _, rule := range plugin.onResolveRules {
 				callback := rule.Callback
@@ -219,17 +373,21 @@ func (b *BuildOptions) convertPlugin(plugin *Plugin) api.Plugin {
 						Namespace:  args.Namespace,
 						ResolveDir: args.ResolveDir,
 						Kind:       ResolveKindFromAPI(args.Kind),
+						PluginData: registry.lookup(args.PluginData),
+						With:       args.With,
+						Resolver:   resolver,
 					}
 					result := callback.Call(mobileArgs)
 					return api.OnResolveResult{
 						PluginName:  result.PluginName,
-						Errors:      result.Errors,
-						Warnings:    result.Warnings,
+						Errors:      messagesToAPI(result.Errors),
+						Warnings:    messagesToAPI(result.Warnings),
 						Path:        result.Path,
 						External:    result.External,
 						SideEffects: result.SideEffects.ToAPI(),
 						Namespace:   result.Namespace,
 						Suffix:      result.Suffix,
+						PluginData:  registry.store(result.PluginData),
 						WatchFiles:  result.WatchFiles,
 						WatchDirs:   result.WatchDirs,
 					}, nil
@@ -241,48 +399,55 @@ func (b *BuildOptions) convertPlugin(plugin *Plugin) api.Plugin {
 				callback := rule.Callback
 				build.OnLoad(rule.Options.ToAPI(), func(args api.OnLoadArgs) (api.OnLoadResult, error) {
 					mobileArgs := &OnLoadArgs{
-						Path:      args.Path,
-						Namespace: args.Namespace,
-						Suffix:    args.Suffix,
+						Path:       args.Path,
+						Namespace:  args.Namespace,
+						Suffix:     args.Suffix,
+						PluginData: registry.lookup(args.PluginData),
+						With:       args.With,
+						Resolver:   resolver,
 					}
 					result := callback.Call(mobileArgs)
 					return api.OnLoadResult{
 						PluginName: result.PluginName,
-						Errors:     result.Errors,
-						Warnings:   result.Warnings,
+						Errors:     messagesToAPI(result.Errors),
+						Warnings:   messagesToAPI(result.Warnings),
 						Contents:   result.Contents,
 						ResolveDir: result.ResolveDir,
 						Loader:     result.Loader,
+						PluginData: registry.store(result.PluginData),
 						WatchFiles: result.WatchFiles,
 						WatchDirs:  result.WatchDirs,
 					}, nil
 				})
 			}
 
-			// Register onStart callback
-			if plugin.onStartCallback != nil {
-				callback := plugin.onStartCallback
+			// Register onStart callbacks. esbuild runs the start callbacks of
+			// a plugin in parallel, so they are registered as one to keep their order.
+			if len(plugin.onStartCallbacks) > 0 {
+				callbacks := plugin.onStartCallbacks
 				build.OnStart(func() (api.OnStartResult, error) {
-					result := callback.Call()
-					return api.OnStartResult{
-						Errors:   result.Errors,
-						Warnings: result.Warnings,
-					}, nil
+					var startResult api.OnStartResult
+					for _, callback := range callbacks {
+						if result := callback.Call(); result != nil {
+							startResult.Errors = append(startResult.Errors, messagesToAPI(result.Errors)...)
+							startResult.Warnings = append(startResult.Warnings, messagesToAPI(result.Warnings)...)
+						}
+					}
+					return startResult, nil
 				})
 			}
 
-			// Register onEnd callback
-			if plugin.onEndCallback != nil {
-				callback := plugin.onEndCallback
+			// Register onEnd callbacks
+			for _, callback := range plugin.onEndCallbacks {
 				build.OnEnd(func(result *api.BuildResult) (api.OnEndResult, error) {
-					mobileResult := &BuildResult{
-						Errors:   result.Errors,
-						Warnings: result.Warnings,
-					}
+					mobileResult := buildResultFromAPI(result)
 					endResult := callback.Call(mobileResult)
+					if endResult == nil {
+						return api.OnEndResult{}, nil
+					}
 					return api.OnEndResult{
-						Errors:   endResult.Errors,
-						Warnings: endResult.Warnings,
+						Errors:   messagesToAPI(endResult.Errors),
+						Warnings: messagesToAPI(endResult.Warnings),
 					}, nil
 				})
 			}
@@ -308,6 +473,32 @@ func (b *BuildOptions) ConfigureSourcefile(sf string)           { b.Sourcefile =
 func (b *BuildOptions) ConfigurePublicPath(path string)         { b.PublicPath = path }
 func (b *BuildOptions) ConfigureAbsWorkingDir(dir string)       { b.AbsWorkingDir = dir }
 
+// Cancellation options
+func (b *BuildOptions) ConfigureCancelHandle(handle *CancelHandle) { b.CancelHandle = handle }
+func (b *BuildOptions) ConfigureTimeoutMillis(timeout int)         { b.TimeoutMillis = timeout }
+
+// Caching options
+func (b *BuildOptions) ConfigureCache(cache *Cache) { b.Cache = cache }
+
+// In-memory inputs
+func (b *BuildOptions) ConfigureVirtualFS(fs *VirtualFS)          { b.VirtualFS = fs }
+func (b *BuildOptions) ConfigurePackageStore(store *PackageStore) { b.PackageStore = store }
+func (b *BuildOptions) ConfigureImportMap(importMap *ImportMap)   { b.ImportMap = importMap }
+func (b *BuildOptions) ConfigureURLImports(imports *URLImports)   { b.URLImports = imports }
+
+// ConfigureImportMapJSON parses and sets an import map
+func (b *BuildOptions) ConfigureImportMapJSON(text string) error {
+	importMap, err := ParseImportMap(text)
+	if err != nil {
+		return err
+	}
+	b.ImportMap = importMap
+	return nil
+}
+
+// Output options
+func (b *BuildOptions) ConfigureOutputSink(sink OutputSink) { b.OutputSink = sink }
+
 // Minification options
 func (b *BuildOptions) ConfigureMinifyWhitespace(v bool)        { b.MinifyWhitespace = v }
 func (b *BuildOptions) ConfigureMinifyIdentifiers(v bool)       { b.MinifyIdentifiers = v }
@@ -1182,3 +1373,13 @@ func (b *BuildOptions) ClearBanner()              { b.Banner = nil }
 func (b *BuildOptions) ClearFooter()              { b.Footer = nil }
 func (b *BuildOptions) ClearSupported()           { b.Supported = nil }
 func (b *BuildOptions) ClearMangleCache()         { b.MangleCache = nil }
+
+// ConfigureMangleCacheJSON sets the mangle cache from JSON, such as the
+// value returned by BuildResult.GetMangleCacheJSON
+func (b *BuildOptions) ConfigureMangleCacheJSON(cache string) error {
+	if cache == "" {
+		b.MangleCache = nil
+		return nil
+	}
+	return json.Unmarshal([]byte(cache), &b.MangleCache)
+}
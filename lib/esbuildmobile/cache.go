@@ -0,0 +1,527 @@
+package esbuildmobile
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CacheBackend stores cached results by key. Keys are lowercase hex strings
+// that are safe to use as file names. Implementations must be safe for
+// concurrent use and may drop entries at any time.
+type CacheBackend interface {
+	// Get returns the stored value, or nil on a miss
+	Get(key string) []byte
+	// Put stores a value, replacing any existing value for the key
+	Put(key string, value []byte) error
+}
+
+// Cache is a content-addressed cache of transform and build results. Attach
+// it to TransformOptions or BuildOptions to skip work whose input and options
+// have not changed. Results are keyed by a hash of the input and every option
+// except CancelHandle, TimeoutMillis and Cache itself.
+//
+// Build results also record a hash of every file read from disk, and a cached
+// build is only reused while those files are unchanged. Builds with plugins
+// are only cached when every plugin has a cache key (see Plugin.SetCacheKey).
+// Failed transforms and builds are never cached.
+type Cache struct {
+	backend CacheBackend
+
+	hits   atomic.Int64
+	misses atomic.Int64
+	stores atomic.Int64
+}
+
+// NewCache creates a cache on top of a backend
+func NewCache(backend CacheBackend) *Cache {
+	return &Cache{backend: backend}
+}
+
+// NewMemoryCache creates a cache kept in memory, see NewMemoryCacheBackend
+func NewMemoryCache(maxBytes int) *Cache {
+	return NewCache(NewMemoryCacheBackend(maxBytes))
+}
+
+// NewDiskCache creates a cache stored in a directory, see NewDiskCacheBackend
+func NewDiskCache(dir string, maxBytes int) (*Cache, error) {
+	backend, err := NewDiskCacheBackend(dir, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	return NewCache(backend), nil
+}
+
+// GetBackend returns the backend the cache stores results in
+func (c *Cache) GetBackend() CacheBackend { return c.backend }
+
+// Statistics
+
+func (c *Cache) GetHits() int   { return int(c.hits.Load()) }
+func (c *Cache) GetMisses() int { return int(c.misses.Load()) }
+func (c *Cache) GetStores() int { return int(c.stores.Load()) }
+
+// GetHitRate returns hits divided by lookups, or 0 before the first lookup
+func (c *Cache) GetHitRate() float64 {
+	hits, misses := c.hits.Load(), c.misses.Load()
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
+
+// ResetStats sets every counter back to zero without touching stored entries
+func (c *Cache) ResetStats() {
+	c.hits.Store(0)
+	c.misses.Store(0)
+	c.stores.Store(0)
+}
+
+// cacheVersion is part of every key so that a new release of this package or
+// of esbuild never reuses results produced by an older one
+var cacheVersion = sync.OnceValue(func() string {
+	version := "esbuildmobile-cache-1"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path == "github.com/evanw/esbuild" {
+				version += " esbuild@" + dep.Version
+			}
+		}
+	}
+	return version
+})
+
+// key hashes the kind of work, the input and the options. It returns "" when
+// the options cannot be encoded or a plugin has no cache key.
+func (c *Cache) key(kind string, input string, options interface{}, plugins []*Plugin) string {
+	encodedOptions, err := json.Marshal(options)
+	if err != nil {
+		return ""
+	}
+	pluginKeys := make([]string, 0, len(plugins))
+	for _, plugin := range plugins {
+		if plugin == nil {
+			continue
+		}
+		if plugin.cacheKey == "" {
+			return ""
+		}
+		pluginKeys = append(pluginKeys, plugin.name, plugin.cacheKey)
+	}
+	encoded, err := json.Marshal([]interface{}{cacheVersion(), kind, input, json.RawMessage(encodedOptions), pluginKeys})
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+// lookup decodes the entry for key into value, counting a hit or a miss
+func (c *Cache) lookup(key string, value interface{}, valid func() bool) bool {
+	data := c.backend.Get(key)
+	if data == nil || json.Unmarshal(data, value) != nil || (valid != nil && !valid()) {
+		c.misses.Add(1)
+		return false
+	}
+	c.hits.Add(1)
+	return true
+}
+
+// store encodes and saves a value, ignoring backend errors
+func (c *Cache) store(key string, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	if c.backend.Put(key, data) == nil {
+		c.stores.Add(1)
+	}
+}
+
+// buildCacheEntry is a cached build together with the files it read from disk
+type buildCacheEntry struct {
+	Result *BuildResult
+	Files  map[string]string // absolute path to SHA-256 of the contents
+}
+
+// valid reports whether every recorded file still has the same contents
+func (e *buildCacheEntry) valid() bool {
+	if e.Result == nil {
+		return false
+	}
+	for path, hash := range e.Files {
+		if current, ok := hashFile(path); !ok || current != hash {
+			return false
+		}
+	}
+	return true
+}
+
+// inputFiles maps every recorded file to its path relative to workingDir,
+// in the form writeOutputFiles expects
+func (e *buildCacheEntry) inputFiles(workingDir string) map[string]string {
+	if workingDir == "" {
+		workingDir, _ = os.Getwd()
+	}
+	inputs := make(map[string]string, len(e.Files))
+	for path := range e.Files {
+		input := path
+		if relative, err := filepath.Rel(workingDir, path); err == nil {
+			input = filepath.ToSlash(relative)
+		}
+		inputs[path] = input
+	}
+	return inputs
+}
+
+// buildInputFiles hashes every input of a metafile that was read from disk.
+// Inputs from other namespaces are covered by the plugin cache keys and stdin
+// is part of the cache key. It returns false if a file cannot be read.
+func buildInputFiles(metafile string, workingDir string) (map[string]string, bool) {
+	inputs, ok := metafileInputFiles(metafile, workingDir)
+	if !ok {
+		return nil, false
+	}
+	files := make(map[string]string, len(inputs))
+	for path := range inputs {
+		hash, ok := hashFile(path)
+		if !ok {
+			return nil, false
+		}
+		files[path] = hash
+	}
+	return files, true
+}
+
+// metafileInputFiles maps the absolute path of every input in the "file"
+// namespace to its path in the metafile
+func metafileInputFiles(metafile string, workingDir string) (map[string]string, bool) {
+	parsed, err := ParseMetafile(metafile)
+	if err != nil {
+		return nil, false
+	}
+	if workingDir == "" {
+		if workingDir, err = os.Getwd(); err != nil {
+			return nil, false
+		}
+	}
+
+	inputs := make(map[string]string, len(parsed.Inputs))
+	for _, input := range parsed.Inputs {
+		if input.Path == "<stdin>" || metafileNamespace(input.Path) != "" {
+			continue
+		}
+		path := filepath.FromSlash(input.Path)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(workingDir, path)
+		}
+		inputs[path] = input.Path
+	}
+	return inputs, true
+}
+
+// metafileNamespace returns the namespace prefix of a metafile path, which
+// esbuild adds to every path outside of the "file" namespace ("virtual:a.js")
+func metafileNamespace(path string) string {
+	colon := strings.IndexByte(path, ':')
+	if colon <= 0 || strings.ContainsAny(path[:colon], `/\`) {
+		return ""
+	}
+	return path[:colon]
+}
+
+func hashFile(path string) (string, bool) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(contents)
+	return hex.EncodeToString(sum[:]), true
+}
+
+// MemoryCacheBackend keeps entries in memory and evicts the least recently
+// used ones once the total size of keys and values exceeds its byte budget
+type MemoryCacheBackend struct {
+	mutex    sync.Mutex
+	maxBytes int
+	size     int
+	order    *list.List // most recently used first
+	entries  map[string]*list.Element
+}
+
+type memoryCacheEntry struct {
+	key   string
+	value []byte
+}
+
+// NewMemoryCacheBackend creates an empty in-memory backend. A budget of 0 or less means no limit.
+func NewMemoryCacheBackend(maxBytes int) *MemoryCacheBackend {
+	return &MemoryCacheBackend{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+func (b *MemoryCacheBackend) Get(key string) []byte {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	element, ok := b.entries[key]
+	if !ok {
+		return nil
+	}
+	b.order.MoveToFront(element)
+	return element.Value.(*memoryCacheEntry).value
+}
+
+// Put stores a copy of the value. Values larger than the whole budget are not stored.
+func (b *MemoryCacheBackend) Put(key string, value []byte) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.remove(key)
+	entrySize := len(key) + len(value)
+	if b.maxBytes > 0 && entrySize > b.maxBytes {
+		return nil
+	}
+
+	entry := &memoryCacheEntry{key: key, value: append([]byte(nil), value...)}
+	b.entries[key] = b.order.PushFront(entry)
+	b.size += entrySize
+
+	for b.maxBytes > 0 && b.size > b.maxBytes {
+		oldest := b.order.Back()
+		b.remove(oldest.Value.(*memoryCacheEntry).key)
+	}
+	return nil
+}
+
+// Remove deletes a single entry
+func (b *MemoryCacheBackend) Remove(key string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.remove(key)
+}
+
+func (b *MemoryCacheBackend) remove(key string) {
+	element, ok := b.entries[key]
+	if !ok {
+		return
+	}
+	entry := element.Value.(*memoryCacheEntry)
+	b.order.Remove(element)
+	delete(b.entries, key)
+	b.size -= len(entry.key) + len(entry.value)
+}
+
+// Clear deletes every entry
+func (b *MemoryCacheBackend) Clear() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.order.Init()
+	b.entries = make(map[string]*list.Element)
+	b.size = 0
+}
+
+// Getter methods for MemoryCacheBackend
+
+func (b *MemoryCacheBackend) GetMaxBytes() int { return b.maxBytes }
+
+func (b *MemoryCacheBackend) GetSize() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.size
+}
+
+func (b *MemoryCacheBackend) GetEntriesCount() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return len(b.entries)
+}
+
+// DiskCacheBackend stores one file per entry in a directory, so results
+// survive app restarts. Once the total size of the files exceeds the byte
+// budget, the least recently used files are deleted.
+type DiskCacheBackend struct {
+	mutex    sync.Mutex
+	dir      string
+	maxBytes int
+	size     int
+}
+
+// NewDiskCacheBackend creates the directory if needed and picks up any
+// entries already stored in it. A budget of 0 or less means no limit.
+func NewDiskCacheBackend(dir string, maxBytes int) (*DiskCacheBackend, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	b := &DiskCacheBackend{dir: dir, maxBytes: maxBytes}
+	files, err := b.files()
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		b.size += int(file.size)
+	}
+	return b, nil
+}
+
+func (b *DiskCacheBackend) Get(key string) []byte {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	path := filepath.Join(b.dir, key)
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	// The modification time doubles as the last access time for eviction
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return contents
+}
+
+// Put writes the value to a temporary file and renames it into place, so
+// readers never see a partially written entry
+func (b *DiskCacheBackend) Put(key string, value []byte) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.maxBytes > 0 && len(value) > b.maxBytes {
+		return nil
+	}
+
+	temp, err := os.CreateTemp(b.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = temp.Write(value)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+
+	path := filepath.Join(b.dir, key)
+	if info, err := os.Stat(path); err == nil {
+		b.size -= int(info.Size())
+	}
+	if err := os.Rename(temp.Name(), path); err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+	b.size += len(value)
+
+	if b.maxBytes > 0 && b.size > b.maxBytes {
+		return b.evict()
+	}
+	return nil
+}
+
+// evict deletes the least recently used files until the directory fits the budget
+func (b *DiskCacheBackend) evict() error {
+	files, err := b.files()
+	if err != nil {
+		return err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+	b.size = 0
+	for _, file := range files {
+		b.size += int(file.size)
+	}
+	for _, file := range files {
+		if b.size <= b.maxBytes {
+			break
+		}
+		if err := os.Remove(filepath.Join(b.dir, file.name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		b.size -= int(file.size)
+	}
+	return nil
+}
+
+type diskCacheFile struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+// files lists every entry, skipping temporary files and subdirectories
+func (b *DiskCacheBackend) files() ([]diskCacheFile, error) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]diskCacheFile, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, diskCacheFile{name: entry.Name(), size: info.Size(), modTime: info.ModTime()})
+	}
+	return files, nil
+}
+
+// Remove deletes a single entry
+func (b *DiskCacheBackend) Remove(key string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	path := filepath.Join(b.dir, key)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	b.size -= int(info.Size())
+	return nil
+}
+
+// Clear deletes every entry, leaving the directory in place
+func (b *DiskCacheBackend) Clear() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	files, err := b.files()
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := os.Remove(filepath.Join(b.dir, file.name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	b.size = 0
+	return nil
+}
+
+// Getter methods for DiskCacheBackend
+
+func (b *DiskCacheBackend) GetDir() string   { return b.dir }
+func (b *DiskCacheBackend) GetMaxBytes() int { return b.maxBytes }
+
+func (b *DiskCacheBackend) GetSize() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.size
+}
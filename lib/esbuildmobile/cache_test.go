@@ -0,0 +1,161 @@
+package esbuildmobile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTransformCache(t *testing.T) {
+	cache := NewMemoryCache(1 << 20)
+	options := NewTransformOptions()
+	options.ConfigureCache(cache)
+
+	for i := 0; i < 2; i++ {
+		result, err := TransformJSXWithResult("let a = <div />", options)
+		if err != nil {
+			t.Fatal("transform failed: ", err)
+		}
+		if !strings.Contains(result.Code, "React.createElement") {
+			t.Fatalf("unexpected output: %s", result.Code)
+		}
+	}
+	if cache.GetHits() != 1 || cache.GetMisses() != 1 || cache.GetStores() != 1 {
+		t.Fatalf("expected 1 hit, 1 miss and 1 store, got %d, %d and %d", cache.GetHits(), cache.GetMisses(), cache.GetStores())
+	}
+
+	// Any option change is a different entry
+	options.ConfigureMinifyWhitespace(true)
+	if _, err := TransformJSXWithResult("let a = <div />", options); err != nil {
+		t.Fatal("transform failed: ", err)
+	}
+	if cache.GetMisses() != 2 {
+		t.Fatalf("expected a miss after changing options, got %d misses", cache.GetMisses())
+	}
+
+	// Failed transforms are not stored
+	if _, err := TransformJSXWithResult("let a = <div", options); err == nil {
+		t.Fatal("expected a syntax error")
+	}
+	if cache.GetStores() != 2 {
+		t.Fatalf("expected failed transform to be skipped, got %d stores", cache.GetStores())
+	}
+}
+
+func TestMemoryCacheBackendEviction(t *testing.T) {
+	backend := NewMemoryCacheBackend(20)
+	backend.Put("a", []byte("123456789"))
+	backend.Put("b", []byte("123456789"))
+	backend.Get("a")
+	backend.Put("c", []byte("123456789"))
+
+	if backend.Get("b") != nil {
+		t.Fatal("expected least recently used entry to be evicted")
+	}
+	if backend.Get("a") == nil || backend.Get("c") == nil {
+		t.Fatal("expected recently used entries to be kept")
+	}
+	if backend.GetSize() != 20 || backend.GetEntriesCount() != 2 {
+		t.Fatalf("unexpected size %d with %d entries", backend.GetSize(), backend.GetEntriesCount())
+	}
+}
+
+func TestDiskCacheBackend(t *testing.T) {
+	dir := t.TempDir()
+	backend, err := NewDiskCacheBackend(dir, 25)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		if err := backend.Put(key, []byte("0123456789")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if backend.Get("a") != nil {
+		t.Fatal("expected oldest entry to be evicted")
+	}
+
+	// Entries survive reopening the directory
+	reopened, err := NewDiskCacheBackend(dir, 25)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(reopened.Get("c")) != "0123456789" || reopened.GetSize() != 20 {
+		t.Fatalf("unexpected entry after reopening, size %d", reopened.GetSize())
+	}
+}
+
+func TestBuildCacheTracksFiles(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "value.js")
+	writeValue := func(value int) {
+		if err := os.WriteFile(file, []byte(fmt.Sprintf("export default %d", value)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entry := `import value from "./value.js"; console.log(value)`
+	if err := os.WriteFile(filepath.Join(dir, "entry.js"), []byte(entry), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cache, err := NewDiskCache(filepath.Join(dir, "cache"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	build := func() string {
+		options := NewBuildOptions()
+		options.ConfigureBundle(true)
+		options.ConfigureAbsWorkingDir(dir)
+		options.ConfigureCache(cache)
+		options.AddEntryPoint("entry.js")
+		code, err := Build("", options)
+		if err != nil {
+			t.Fatal("build failed: ", err)
+		}
+		return code
+	}
+
+	writeValue(1)
+	build()
+	if code := build(); !strings.Contains(code, "= 1") || cache.GetHits() != 1 {
+		t.Fatalf("expected cached build, got %d hits:\n%s", cache.GetHits(), code)
+	}
+
+	writeValue(2)
+	if code := build(); !strings.Contains(code, "= 2") || cache.GetHits() != 1 {
+		t.Fatalf("expected rebuild after the file changed, got %d hits:\n%s", cache.GetHits(), code)
+	}
+}
+
+func TestBuildCacheSkipsPluginsWithoutKey(t *testing.T) {
+	cache := NewMemoryCache(0)
+	loads, starts := 0, 0
+	plugin := counterPlugin(&loads, &starts)
+
+	options := NewBuildOptions()
+	options.ConfigureBundle(true)
+	options.ConfigureCache(cache)
+	options.AddPlugin(plugin)
+
+	input := `import n from "virtual:counter"; console.log(n)`
+	for i := 0; i < 2; i++ {
+		if _, err := Build(input, options); err != nil {
+			t.Fatal("build failed: ", err)
+		}
+	}
+	if loads != 2 || cache.GetStores() != 0 {
+		t.Fatalf("expected uncached builds, got %d loads and %d stores", loads, cache.GetStores())
+	}
+
+	plugin.SetCacheKey("v1")
+	for i := 0; i < 2; i++ {
+		if _, err := Build(input, options); err != nil {
+			t.Fatal("build failed: ", err)
+		}
+	}
+	if loads != 3 || cache.GetHits() != 1 {
+		t.Fatalf("expected one cached build, got %d loads and %d hits", loads, cache.GetHits())
+	}
+}
@@ -0,0 +1,115 @@
+package esbuildmobile
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+var (
+	// ErrBuildCancelled is returned when a build or transform is cancelled through its CancelHandle
+	ErrBuildCancelled = errors.New("build was cancelled")
+	// ErrBuildTimeout is returned when a build or transform exceeds its TimeoutMillis
+	ErrBuildTimeout = errors.New("build timed out")
+)
+
+// CancelHandle cancels in-progress builds and transforms. Attach it to
+// BuildOptions or TransformOptions before starting the work, then call Cancel
+// from any thread. A handle stays cancelled once Cancel has been called.
+type CancelHandle struct {
+	once sync.Once
+	done chan struct{}
+}
+
+// NewCancelHandle creates a handle that has not been cancelled
+func NewCancelHandle() *CancelHandle {
+	return &CancelHandle{done: make(chan struct{})}
+}
+
+// Cancel stops every build and transform using this handle. It is safe to call more than once.
+func (h *CancelHandle) Cancel() {
+	h.once.Do(func() { close(h.done) })
+}
+
+// IsCancelled reports whether Cancel has been called
+func (h *CancelHandle) IsCancelled() bool {
+	select {
+	case <-h.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+// Done returns a channel that is closed on cancellation. A nil handle is never cancelled.
+func (h *CancelHandle) Done() <-chan struct{} {
+	if h == nil {
+		return nil
+	}
+	return h.done
+}
+
+// waitCancellable waits for a result, returning early with ErrBuildCancelled
+// or ErrBuildTimeout. The caller is responsible for stopping the work.
+// A timeout of 0 or less means no timeout.
+func waitCancellable[T any](result <-chan T, handle *CancelHandle, timeoutMillis int) (T, error) {
+	var zero T
+	if handle.IsCancelled() {
+		return zero, ErrBuildCancelled
+	}
+
+	var timeout <-chan time.Time
+	if timeoutMillis > 0 {
+		timer := time.NewTimer(time.Duration(timeoutMillis) * time.Millisecond)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case value := <-result:
+		return value, nil
+	case <-handle.Done():
+		return zero, ErrBuildCancelled
+	case <-timeout:
+		return zero, ErrBuildTimeout
+	}
+}
+
+// buildCancellable runs a one-off build through a context so that it can be
+// cancelled. The build is cut short with ctx.Cancel and no result is returned.
+func buildCancellable(buildOpts api.BuildOptions, handle *CancelHandle, timeoutMillis int) (api.BuildResult, error) {
+	if handle.IsCancelled() {
+		return api.BuildResult{}, ErrBuildCancelled
+	}
+
+	ctx, ctxErr := api.Context(buildOpts)
+	if ctxErr != nil {
+		return api.BuildResult{Errors: ctxErr.Errors}, nil
+	}
+	defer ctx.Dispose()
+
+	results := make(chan api.BuildResult, 1)
+	go func() { results <- ctx.Rebuild() }()
+
+	result, err := waitCancellable(results, handle, timeoutMillis)
+	if err != nil {
+		ctx.Cancel()
+	}
+	return result, err
+}
+
+// transformCancellable runs a transform in the background and stops waiting
+// for it on cancellation. esbuild cannot interrupt a transform, so the
+// abandoned work finishes on its own and its result is discarded.
+func transformCancellable(input string, transformOpts api.TransformOptions, handle *CancelHandle, timeoutMillis int) (api.TransformResult, error) {
+	if handle.IsCancelled() {
+		return api.TransformResult{}, ErrBuildCancelled
+	}
+
+	results := make(chan api.TransformResult, 1)
+	go func() { results <- api.Transform(input, transformOpts) }()
+
+	return waitCancellable(results, handle, timeoutMillis)
+}
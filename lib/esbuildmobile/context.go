@@ -0,0 +1,246 @@
+package esbuildmobile
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// OnRebuildCallback receives the result of every build started by watch
+// mode, including the initial build started by Watch. Builds started by
+// Rebuild are only returned from Rebuild.
+type OnRebuildCallback interface {
+	Call(result *BuildResult)
+}
+
+// ErrContextDisposed is returned when a disposed BuildContext is used
+var ErrContextDisposed = errors.New("build context has been disposed")
+
+// BuildContext is a long-lived incremental build created from BuildOptions.
+// Plugins are set up once and stay registered for every rebuild, and esbuild
+// reuses its caches between rebuilds so only changed files are reparsed.
+//
+// The input passed to NewBuildContext is fixed for the lifetime of the
+// context; sources that change between rebuilds should be read from disk
+// or provided by a plugin.
+// Documentation: https://esbuild.github.io/api/#build
+type BuildContext struct {
+	ctx     api.BuildContext
+	host    *pluginHost
+	options *BuildOptions
+
+	mutex         sync.Mutex
+	disposed      bool
+	watching      bool
+	onRebuild     OnRebuildCallback
+	rebuildCalled bool // Rebuild is waiting for a build to start
+	manualBuild   bool // the running build was started by Rebuild
+	activeCancel  *CancelHandle
+	cancelHandle  *CancelHandle
+	timeoutMillis int
+}
+
+// NewBuildContext creates an incremental build context. No build is run until
+// Rebuild is called.
+// `BuildOptions` is optional.
+func NewBuildContext(input string, options *BuildOptions) (*BuildContext, error) {
+	if options == nil {
+		options = NewBuildOptions()
+	}
+
+	c := &BuildContext{
+		host:          newPluginHost(),
+		options:       options,
+		cancelHandle:  options.CancelHandle,
+		timeoutMillis: options.TimeoutMillis,
+	}
+	buildOpts := options.toAPIBuildOptionsWithInput(input, c.host)
+	buildOpts.Plugins = append(buildOpts.Plugins, c.rebuildPlugin())
+
+	ctx, ctxErr := api.Context(buildOpts)
+	if ctxErr != nil {
+		c.host.dispose()
+		if err := newBuildError(messagesFromAPI(ctxErr.Errors)); err != nil {
+			return nil, err
+		}
+		return nil, ctxErr
+	}
+
+	c.ctx = ctx
+	return c, nil
+}
+
+// rebuildPlugin forwards the result of every build started by watch mode to
+// the OnRebuild callback. It is registered after the user plugins so their
+// OnEnd callbacks have already run.
+func (c *BuildContext) rebuildPlugin() api.Plugin {
+	return api.Plugin{
+		Name: "esbuildmobile-rebuild",
+		Setup: func(build api.PluginBuild) {
+			build.OnStart(func() (api.OnStartResult, error) {
+				c.mutex.Lock()
+				c.manualBuild = c.rebuildCalled
+				c.rebuildCalled = false
+				c.mutex.Unlock()
+				return api.OnStartResult{}, nil
+			})
+			build.OnEnd(func(result *api.BuildResult) (api.OnEndResult, error) {
+				c.mutex.Lock()
+				callback := c.onRebuild
+				if c.manualBuild {
+					callback = nil
+				}
+				c.mutex.Unlock()
+
+				if callback != nil {
+					callback.Call(c.options.resultFromAPI(result))
+				}
+				return api.OnEndResult{}, nil
+			})
+		},
+	}
+}
+
+// Rebuild runs an incremental build and returns the full result.
+// Returns ErrBuildCancelled if Cancel or the Cancel method of
+// BuildOptions.CancelHandle is called while the build is running, or
+// ErrBuildTimeout if it exceeds BuildOptions.TimeoutMillis. A cancelled
+// BuildOptions.CancelHandle stays cancelled, so later rebuilds fail too.
+func (c *BuildContext) Rebuild() (*BuildResult, error) {
+	c.mutex.Lock()
+	if c.disposed {
+		c.mutex.Unlock()
+		return nil, ErrContextDisposed
+	}
+	ctx := c.ctx
+	handle := NewCancelHandle()
+	c.activeCancel = handle
+	c.rebuildCalled = true
+	c.mutex.Unlock()
+
+	// A build that never started must not mark the next watch build
+	defer func() {
+		c.mutex.Lock()
+		c.rebuildCalled = false
+		c.mutex.Unlock()
+	}()
+
+	// Forward the handle from the options to this rebuild until it finishes
+	if c.cancelHandle != nil {
+		finished := make(chan struct{})
+		defer close(finished)
+		go func() {
+			select {
+			case <-c.cancelHandle.Done():
+				handle.Cancel()
+			case <-finished:
+			}
+		}()
+	}
+
+	results := make(chan api.BuildResult, 1)
+	go func() { results <- ctx.Rebuild() }()
+
+	result, err := waitCancellable(results, handle, c.timeoutMillis)
+	if err != nil {
+		ctx.Cancel()
+		return nil, err
+	}
+	buildResult := c.options.resultFromAPI(&result)
+	return buildResult, newBuildError(buildResult.Errors)
+}
+
+// Cancel stops the build started by Rebuild, if any, which then returns
+// ErrBuildCancelled. The context stays usable for later rebuilds.
+// Documentation: https://esbuild.github.io/api/#cancel
+func (c *BuildContext) Cancel() {
+	c.mutex.Lock()
+	handle := c.activeCancel
+	c.activeCancel = nil
+	c.mutex.Unlock()
+
+	if handle != nil {
+		handle.Cancel()
+	}
+}
+
+// Watch starts watch mode. esbuild rebuilds whenever a file that was part of
+// the last build changes, including every file and directory that plugins
+// listed in WatchFiles and WatchDirs, and passes each result to callback.
+// An initial build is started immediately and passed to callback too, while
+// the results of later Rebuild calls are not. `OnRebuildCallback` is optional.
+// Documentation: https://esbuild.github.io/api/#watch
+func (c *BuildContext) Watch(callback OnRebuildCallback) error {
+	c.mutex.Lock()
+	if c.disposed {
+		c.mutex.Unlock()
+		return ErrContextDisposed
+	}
+	c.onRebuild = callback
+	ctx := c.ctx
+	c.mutex.Unlock()
+
+	if err := ctx.Watch(api.WatchOptions{}); err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	c.watching = true
+	c.mutex.Unlock()
+	return nil
+}
+
+// IsWatching reports whether watch mode is active
+func (c *BuildContext) IsWatching() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.watching
+}
+
+// Dispose releases the resources of the context, waiting for a running
+// build and then running the OnDispose callbacks of every plugin.
+// It is safe to call more than once.
+func (c *BuildContext) Dispose() {
+	c.mutex.Lock()
+	if c.disposed {
+		c.mutex.Unlock()
+		return
+	}
+	c.disposed = true
+	c.watching = false
+	ctx := c.ctx
+	c.mutex.Unlock()
+
+	ctx.Dispose()
+	c.host.dispose()
+}
+
+// IsDisposed reports whether Dispose has been called
+func (c *BuildContext) IsDisposed() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.disposed
+}
+
+// SwiftOnRebuildCallback is a concrete implementation that can be used from Swift
+type SwiftOnRebuildCallback struct {
+	handler func(*BuildResult)
+}
+
+// NewSwiftOnRebuildCallback creates a callback that executes a handler
+func NewSwiftOnRebuildCallback() *SwiftOnRebuildCallback {
+	return &SwiftOnRebuildCallback{}
+}
+
+// SetHandler sets the handler function
+func (c *SwiftOnRebuildCallback) SetHandler(handler func(*BuildResult)) {
+	c.handler = handler
+}
+
+// Call implements the OnRebuildCallback interface
+func (c *SwiftOnRebuildCallback) Call(result *BuildResult) {
+	if c.handler != nil {
+		c.handler(result)
+	}
+}
@@ -0,0 +1,266 @@
+package esbuildmobile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// counterPlugin serves `virtual:counter` with contents that change on every load
+func counterPlugin(loads *int, starts *int) *Plugin {
+	plugin := NewPlugin("counter")
+	plugin.OnResolve(CreateFilterForPath("^virtual:counter$"), &SimpleResolveCallback{
+		Path:      "counter.js",
+		Namespace: NamespaceVirtual,
+	})
+
+	loadCallback := NewSwiftOnLoadCallback()
+	loadCallback.SetHandler(func(args *OnLoadArgs) *OnLoadResult {
+		*loads++
+		return CreateJSLoadResult(fmt.Sprintf("export default %d", *loads))
+	})
+	loadOptions := CreateFilterForNamespace(NamespaceVirtual)
+	loadOptions.SetLoadFilter(FilterAllFiles)
+	plugin.OnLoad(loadOptions, loadCallback)
+
+	startCallback := NewSwiftOnStartCallback()
+	startCallback.SetHandler(func() *OnStartResult {
+		*starts++
+		return NewOnStartResult()
+	})
+	plugin.OnStart(startCallback)
+	return plugin
+}
+
+func TestBuildContextRebuild(t *testing.T) {
+	loads, starts := 0, 0
+	options := NewBuildOptions()
+	options.ConfigureBundle(true)
+	options.AddPlugin(counterPlugin(&loads, &starts))
+
+	ctx, err := NewBuildContext(`import n from "virtual:counter"; console.log(n)`, options)
+	if err != nil {
+		t.Fatal("could not create context: ", err)
+	}
+	defer ctx.Dispose()
+
+	for i := 1; i <= 2; i++ {
+		result, err := ctx.Rebuild()
+		if err != nil {
+			t.Fatal("rebuild failed: ", err)
+		}
+		code := result.GetOutputFile(0).GetText()
+		if !strings.Contains(code, fmt.Sprintf("counter_default = %d", i)) {
+			t.Fatalf("rebuild %d: unexpected output:\n%s", i, code)
+		}
+	}
+	if starts != 2 {
+		t.Fatalf("expected OnStart to run for every rebuild, ran %d times", starts)
+	}
+
+	ctx.Dispose()
+	if _, err := ctx.Rebuild(); !errors.Is(err, ErrContextDisposed) {
+		t.Fatalf("expected ErrContextDisposed, got %v", err)
+	}
+}
+
+func TestBuildContextWatchPluginFiles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("watch mode polls the file system")
+	}
+
+	// The plugin reads a file outside of the module graph and asks esbuild to watch it
+	configPath := filepath.Join(t.TempDir(), "config.txt")
+	if err := os.WriteFile(configPath, []byte("first"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	plugin := NewPlugin("config")
+	plugin.OnResolve(CreateFilterForPath("^virtual:config$"), &SimpleResolveCallback{
+		Path:      "config",
+		Namespace: NamespaceVirtual,
+	})
+	loadCallback := NewSwiftOnLoadCallback()
+	loadCallback.SetHandler(func(args *OnLoadArgs) *OnLoadResult {
+		data, _ := os.ReadFile(configPath)
+		result := CreateLoadResult(string(data), api.LoaderText)
+		result.AddLoadWatchFile(configPath)
+		return result
+	})
+	loadOptions := CreateFilterForNamespace(NamespaceVirtual)
+	loadOptions.SetLoadFilter(FilterAllFiles)
+	plugin.OnLoad(loadOptions, loadCallback)
+
+	options := NewBuildOptions()
+	options.ConfigureBundle(true)
+	options.ConfigureLogLevel(api.LogLevelSilent)
+	options.AddPlugin(plugin)
+
+	ctx, err := NewBuildContext(`import config from "virtual:config"; console.log(config)`, options)
+	if err != nil {
+		t.Fatal("could not create context: ", err)
+	}
+	defer ctx.Dispose()
+
+	outputs := make(chan string, 10)
+	callback := NewSwiftOnRebuildCallback()
+	callback.SetHandler(func(result *BuildResult) {
+		if result.GetOutputFilesCount() > 0 {
+			outputs <- result.GetOutputFile(0).GetText()
+		}
+	})
+	if err := ctx.Watch(callback); err != nil {
+		t.Fatal("could not start watching: ", err)
+	}
+
+	waitFor := func(text string) {
+		timeout := time.After(10 * time.Second)
+		for {
+			select {
+			case output := <-outputs:
+				if strings.Contains(output, text) {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("timed out waiting for a rebuild containing %q", text)
+			}
+		}
+	}
+
+	waitFor("first")
+	if err := os.WriteFile(configPath, []byte("second"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("second")
+
+	// Manual rebuilds return their result instead of passing it to the callback
+	if _, err := ctx.Rebuild(); err != nil {
+		t.Fatal("rebuild failed: ", err)
+	}
+	select {
+	case output := <-outputs:
+		t.Fatalf("expected no callback for a manual rebuild, got:\n%s", output)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+// slowPlugin serves `virtual:slow` after waiting for the given duration
+func slowPlugin(delay time.Duration, started chan<- struct{}) *Plugin {
+	plugin := NewPlugin("slow")
+	plugin.OnResolve(CreateFilterForPath("^virtual:slow$"), &SimpleResolveCallback{
+		Path:      "slow.js",
+		Namespace: NamespaceVirtual,
+	})
+	loadCallback := NewSwiftOnLoadCallback()
+	loadCallback.SetHandler(func(args *OnLoadArgs) *OnLoadResult {
+		if started != nil {
+			started <- struct{}{}
+		}
+		time.Sleep(delay)
+		return CreateJSLoadResult("export default 1")
+	})
+	loadOptions := CreateFilterForNamespace(NamespaceVirtual)
+	loadOptions.SetLoadFilter(FilterAllFiles)
+	plugin.OnLoad(loadOptions, loadCallback)
+	return plugin
+}
+
+func TestBuildCancellation(t *testing.T) {
+	started := make(chan struct{}, 1)
+	handle := NewCancelHandle()
+
+	options := NewBuildOptions()
+	options.ConfigureBundle(true)
+	options.ConfigureLogLevel(api.LogLevelSilent)
+	options.ConfigureCancelHandle(handle)
+	options.AddPlugin(slowPlugin(200*time.Millisecond, started))
+
+	go func() {
+		<-started
+		handle.Cancel()
+	}()
+
+	result, err := BuildWithResult(`import "virtual:slow"`, options)
+	if !errors.Is(err, ErrBuildCancelled) || result != nil {
+		t.Fatalf("expected ErrBuildCancelled without a result, got %v, %v", result, err)
+	}
+
+	// A handle that was already cancelled stops the transform before it starts
+	transformOptions := NewTransformOptions()
+	transformOptions.ConfigureCancelHandle(handle)
+	if _, err := TransformJSX("<div />", transformOptions); !errors.Is(err, ErrBuildCancelled) {
+		t.Fatalf("expected ErrBuildCancelled from transform, got %v", err)
+	}
+}
+
+func TestBuildTimeout(t *testing.T) {
+	options := NewBuildOptions()
+	options.ConfigureBundle(true)
+	options.ConfigureLogLevel(api.LogLevelSilent)
+	options.ConfigureTimeoutMillis(20)
+	options.AddPlugin(slowPlugin(200*time.Millisecond, nil))
+
+	if _, err := BuildWithResult(`import "virtual:slow"`, options); !errors.Is(err, ErrBuildTimeout) {
+		t.Fatalf("expected ErrBuildTimeout, got %v", err)
+	}
+
+	ctx, err := NewBuildContext(`import "virtual:slow"`, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Dispose()
+	if _, err := ctx.Rebuild(); !errors.Is(err, ErrBuildTimeout) {
+		t.Fatalf("expected ErrBuildTimeout from context, got %v", err)
+	}
+}
+
+func TestBuildContextCancel(t *testing.T) {
+	started := make(chan struct{}, 1)
+	options := NewBuildOptions()
+	options.ConfigureBundle(true)
+	options.ConfigureLogLevel(api.LogLevelSilent)
+	options.AddPlugin(slowPlugin(200*time.Millisecond, started))
+
+	ctx, err := NewBuildContext(`import "virtual:slow"`, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Dispose()
+
+	go func() {
+		<-started
+		ctx.Cancel()
+	}()
+	if _, err := ctx.Rebuild(); !errors.Is(err, ErrBuildCancelled) {
+		t.Fatalf("expected ErrBuildCancelled, got %v", err)
+	}
+
+	// The context is still usable after a cancelled rebuild
+	go func() { <-started }()
+	if _, err := ctx.Rebuild(); err != nil {
+		t.Fatal("rebuild after cancel failed: ", err)
+	}
+
+	// The CancelHandle from the options cancels rebuilds too
+	handle := NewCancelHandle()
+	options.ConfigureCancelHandle(handle)
+	handleCtx, err := NewBuildContext(`import "virtual:slow"`, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handleCtx.Dispose()
+
+	go func() {
+		<-started
+		handle.Cancel()
+	}()
+	if _, err := handleCtx.Rebuild(); !errors.Is(err, ErrBuildCancelled) {
+		t.Fatalf("expected ErrBuildCancelled from the options handle, got %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package esbuildmobile
+
+import "fmt"
+
+// BuildError is returned when a build or transform fails. It carries every
+// error message reported by esbuild, including locations.
+//
+// Go callers can use errors.As to retrieve it. Swift callers receive a plain
+// NSError, so the same data is available from GetBuildError on the result.
+type BuildError struct {
+	Messages []*Message
+}
+
+// newBuildError returns a *BuildError for the given messages, or nil if there are none
+func newBuildError(messages []*Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	return &BuildError{Messages: messages}
+}
+
+// Error returns the first error message, followed by a count of any remaining ones
+func (e *BuildError) Error() string {
+	if len(e.Messages) == 0 {
+		return "error: build failed"
+	}
+	text := fmt.Sprintf("error: %v", e.Messages[0].Text)
+	if location := e.Messages[0].Location; location != nil && location.File != "" {
+		text = fmt.Sprintf("%s:%d:%d: %s", location.File, location.Line, location.Column, text)
+	}
+	if remaining := len(e.Messages) - 1; remaining == 1 {
+		text += " (and 1 more error)"
+	} else if remaining > 1 {
+		text += fmt.Sprintf(" (and %d more errors)", remaining)
+	}
+	return text
+}
+
+// Getter methods for BuildError
+
+func (e *BuildError) GetMessagesCount() int { return len(e.Messages) }
+
+func (e *BuildError) GetMessage(index int) *Message {
+	return getMessage(e.Messages, index)
+}
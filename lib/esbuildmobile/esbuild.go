@@ -1,26 +1,43 @@
 package esbuildmobile
 
 // TODO: make into gomobile pkg
-import (
-	"fmt"
-
-	"github.com/evanw/esbuild/pkg/api"
-)
+import "github.com/evanw/esbuild/pkg/api"
 
 // Returns code and error
 // `TransformOptions` is optional
 func TransformJSX(input string, options *TransformOptions) (code string, err error) {
+	result, err := TransformJSXWithResult(input, options)
+	if result != nil {
+		code = result.Code
+	}
+	return code, err
+}
 
+// TransformJSXWithResult transforms the input and returns the full result,
+// including the source map, legal comments, mangle cache, errors and warnings.
+// Returns ErrBuildCancelled or ErrBuildTimeout, and no result, when the transform is cut short.
+// `TransformOptions` is optional
+func TransformJSXWithResult(input string, options *TransformOptions) (transformResult *TransformResult, err error) {
 	if options == nil {
 		options = &TransformOptions{}
 	}
 
+	cacheKey := ""
+	if options.Cache != nil {
+		if cacheKey = options.Cache.key("transform", input, options, nil); cacheKey != "" {
+			cached := &TransformResult{}
+			if options.Cache.lookup(cacheKey, cached, nil) {
+				return cached, nil
+			}
+		}
+	}
+
 	loader := options.Loader
 	if loader == 0 {
 		loader = api.LoaderJSX
 	}
 
-	result := api.Transform(input, api.TransformOptions{
+	transformOpts := api.TransformOptions{
 		Color:             options.Color,
 		LogLevel:          options.LogLevel,
 		LogLimit:          options.LogLimit,
@@ -60,54 +77,92 @@ func TransformJSX(input string, options *TransformOptions) (code string, err err
 		KeepNames:         options.KeepNames,
 		Sourcefile:        options.Sourcefile,
 		Loader:            loader,
-	})
+	}
 
-	code = string(result.Code)
+	var result api.TransformResult
+	if options.CancelHandle == nil && options.TimeoutMillis <= 0 {
+		result = api.Transform(input, transformOpts)
+	} else if result, err = transformCancellable(input, transformOpts, options.CancelHandle, options.TimeoutMillis); err != nil {
+		return nil, err
+	}
+
+	transformResult = transformResultFromAPI(result)
 
-	if len(result.Errors) != 0 {
-		err = fmt.Errorf("error: %v", result.Errors[0].Text)
+	err = newBuildError(transformResult.Errors)
+	if err == nil && cacheKey != "" {
+		options.Cache.store(cacheKey, transformResult)
 	}
 
-	return code, err
+	return transformResult, err
 }
 
 // Build compiles JavaScript using esbuild's build API with stdin.
-// Returns the bundled code as a string if Write is false.
+// Returns the first output file as a string; use BuildWithResult to get every output file.
 // `BuildOptions` is optional.
 func Build(input string, options *BuildOptions) (code string, err error) {
-	if options == nil {
-		options = NewBuildOptions()
+	result, err := BuildWithResult(input, options)
+	if err != nil {
+		return
 	}
 
-	// Convert to API BuildOptions
-	buildOpts := options.ToAPIBuildOptions()
+	if len(result.OutputFiles) > 0 {
+		code = string(result.OutputFiles[0].Contents)
+	}
+	return
+}
 
-	// Set up stdin if input is provided
-	if input != "" {
-		loader := options.LoaderSingle
-		if loader == 0 {
-			loader = api.LoaderJS
-		}
+// BuildWithResult compiles JavaScript using esbuild's build API with stdin and
+// returns the full result, including every output file, the metafile and the mangle cache.
+// Output files are also passed to BuildOptions.OutputSink when one is set, or
+// written to disk by esbuild when Write is set.
+// Returns ErrBuildCancelled or ErrBuildTimeout, and no result, when the build is cut short.
+// `BuildOptions` is optional.
+func BuildWithResult(input string, options *BuildOptions) (buildResult *BuildResult, err error) {
+	if options == nil {
+		options = NewBuildOptions()
+	}
 
-		buildOpts.Stdin = &api.StdinOptions{
-			Contents:   input,
-			Sourcefile: options.Sourcefile,
-			Loader:     loader,
+	host := newPluginHost()
+	buildOpts := options.toAPIBuildOptionsWithInput(input, host)
+
+	// Builds that esbuild writes to disk itself are not cached, since a
+	// cached result would skip writing the files
+	cacheKey := ""
+	if options.Cache != nil && (!buildOpts.Write || options.OutputSink != nil) {
+		if cacheKey = options.Cache.key("build", input, options, options.allPlugins(true)); cacheKey != "" {
+			cached := &buildCacheEntry{}
+			if options.Cache.lookup(cacheKey, cached, cached.valid) {
+				if options.OutputSink != nil {
+					if messages := options.writeOutputFiles(cached.Result.OutputFiles, cached.inputFiles(options.AbsWorkingDir)); len(messages) > 0 {
+						return cached.Result, newBuildError(messages)
+					}
+				}
+				return cached.Result, nil
+			}
+			// The metafile lists every file the build read, which decides when the entry goes stale
+			buildOpts.Metafile = true
 		}
 	}
 
-	// Force Write to false to get output in memory
-	buildOpts.Write = false
-
-	result := api.Build(buildOpts)
-
-	if len(result.Errors) != 0 {
-		err = fmt.Errorf("error: %v", result.Errors[0].Text)
-		return
+	var result api.BuildResult
+	if options.CancelHandle == nil && options.TimeoutMillis <= 0 {
+		result = api.Build(buildOpts)
+	} else {
+		result, err = buildCancellable(buildOpts, options.CancelHandle, options.TimeoutMillis)
 	}
-
-	if len(result.OutputFiles) > 0 {
-		code = string(result.OutputFiles[0].Contents)
+	host.dispose()
+	if err != nil {
+		return nil, err
+	}
+	buildResult = options.resultFromAPI(&result)
+
+	err = newBuildError(buildResult.Errors)
+	if cacheKey != "" {
+		if err == nil {
+			if files, ok := buildInputFiles(result.Metafile, options.AbsWorkingDir); ok {
+				options.Cache.store(cacheKey, &buildCacheEntry{Result: buildResult, Files: files})
+			}
+		}
 	}
 	return
 }
@@ -1,8 +1,15 @@
 package esbuildmobile
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
 )
 
 func TestTransform(t *testing.T) {
@@ -25,3 +32,257 @@ func TestTransform(t *testing.T) {
 
 	log.Println("transformed string: \n", code)
 }
+
+func TestTransformWithResult(t *testing.T) {
+	options := NewTransformOptions()
+	options.ConfigureSourcemap(api.SourceMapExternal)
+	options.ConfigureSourcefile("snippet.jsx")
+
+	result, err := TransformJSXWithResult(`const el = <div>hi</div>`, options)
+	if err != nil {
+		t.Fatal("could not transform jsx: ", err)
+	}
+
+	if !strings.Contains(result.GetCode(), "React.createElement") {
+		t.Fatalf("unexpected code: %s", result.GetCode())
+	}
+	if !result.HasMap() || !strings.Contains(result.GetMap(), "snippet.jsx") {
+		t.Fatalf("expected source map referencing snippet.jsx, got: %s", result.GetMap())
+	}
+
+	result, err = TransformJSXWithResult(`const a = <div>`, nil)
+	if err == nil {
+		t.Fatal("expected an error for invalid jsx")
+	}
+	if result.GetErrorsCount() == 0 {
+		t.Fatal("expected errors on the result")
+	}
+}
+
+func TestBuildWithResultReturnsEveryOutputFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "app.js"), []byte(`import "./app.css"; console.log("app")`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "app.css"), []byte(`body { color: red }`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	options := NewBuildOptions()
+	options.ConfigureBundle(true)
+	options.ConfigureSourcemap(api.SourceMapExternal)
+	options.ConfigureOutdir(filepath.Join(dir, "out"))
+	options.AddEntryPoint(filepath.Join(dir, "app.js"))
+
+	result, err := BuildWithResult("", options)
+	if err != nil {
+		t.Fatal("build failed: ", err)
+	}
+
+	// app.js, app.js.map, app.css, app.css.map
+	if result.GetOutputFilesCount() != 4 {
+		t.Fatalf("expected 4 output files, got %d", result.GetOutputFilesCount())
+	}
+	css := result.GetOutputFileByPath(filepath.Join(dir, "out", "app.css"))
+	if css == nil || !strings.Contains(css.GetText(), "color: red") {
+		t.Fatal("missing css output file")
+	}
+	if css.GetHash() == "" {
+		t.Fatal("expected output file hash")
+	}
+}
+
+func TestPluginErrorWithLocation(t *testing.T) {
+	plugin := NewPlugin("failing-loader")
+	plugin.OnResolve(CreateFilterForPath("^virtual:broken$"), &SimpleResolveCallback{
+		Path:      "broken.js",
+		Namespace: NamespaceVirtual,
+	})
+
+	loadCallback := NewSwiftOnLoadCallback()
+	loadCallback.SetHandler(func(args *OnLoadArgs) *OnLoadResult {
+		message := NewMessageWithLocation("cannot load module", "broken.js", 3, 7)
+		message.AddNoteText("generated by test", nil)
+		result := NewOnLoadResult()
+		result.AddLoadError(message)
+		return result
+	})
+	loadOptions := CreateFilterForNamespace(NamespaceVirtual)
+	loadOptions.SetLoadFilter(FilterAllFiles)
+	plugin.OnLoad(loadOptions, loadCallback)
+
+	options := NewBuildOptions()
+	options.ConfigureBundle(true)
+	options.ConfigureLogLevel(api.LogLevelSilent)
+	options.AddPlugin(plugin)
+
+	result, err := BuildWithResult(`import "virtual:broken"`, options)
+	if err == nil {
+		t.Fatal("expected build to fail")
+	}
+
+	message := result.GetError(0)
+	if message == nil || message.GetText() != "cannot load module" {
+		t.Fatalf("unexpected error message: %+v", message)
+	}
+	if message.GetPluginName() != "failing-loader" {
+		t.Fatalf("expected plugin name, got %q", message.GetPluginName())
+	}
+	if !message.HasLocation() || message.GetLocation().GetLine() != 3 || message.GetLocation().GetColumn() != 7 {
+		t.Fatalf("unexpected location: %+v", message.GetLocation())
+	}
+	if message.GetNotesCount() == 0 || message.GetNote(0).GetText() != "generated by test" {
+		t.Fatal("expected the plugin note to be preserved")
+	}
+}
+
+func TestBuildErrorCarriesEveryMessage(t *testing.T) {
+	options := NewBuildOptions()
+	options.ConfigureBundle(true)
+	options.ConfigureLogLevel(api.LogLevelSilent)
+	options.ConfigureSourcefile("entry.js")
+
+	result, err := BuildWithResult("import './missing-a'\nimport './missing-b'\n", options)
+
+	var buildErr *BuildError
+	if !errors.As(err, &buildErr) {
+		t.Fatalf("expected *BuildError, got %T: %v", err, err)
+	}
+	if buildErr.GetMessagesCount() != 2 {
+		t.Fatalf("expected 2 errors, got %d: %v", buildErr.GetMessagesCount(), err)
+	}
+	second := buildErr.GetMessage(1).GetLocation()
+	if second == nil || second.GetFile() != "entry.js" || second.GetLine() != 2 {
+		t.Fatalf("unexpected location for second error: %+v", second)
+	}
+	if result.GetBuildError().GetMessagesCount() != 2 {
+		t.Fatal("expected the result to expose the same errors")
+	}
+	if !strings.Contains(err.Error(), "and 1 more error") {
+		t.Fatalf("unexpected error text: %v", err)
+	}
+}
+
+func TestFormatMessages(t *testing.T) {
+	options := NewTransformOptions()
+	options.ConfigureSourcefile("broken.jsx")
+
+	result, err := TransformJSXWithResult("const a = 1\nconst b = <div>\n", options)
+	if err == nil {
+		t.Fatal("expected an error for invalid jsx")
+	}
+
+	formatted := result.FormatErrors(NewFormatMessagesOptions(GetMessageKindError()))
+	if !strings.Contains(formatted, "broken.jsx:") || !strings.Contains(formatted, "^") {
+		t.Fatalf("expected a code frame, got:\n%s", formatted)
+	}
+
+	warning := FormatMessage(NewMessage("heads up"), NewFormatMessagesOptions(GetMessageKindWarning()))
+	if !strings.Contains(warning, "WARNING") || !strings.Contains(warning, "heads up") {
+		t.Fatalf("unexpected warning output: %q", warning)
+	}
+
+	warningOptions := NewFormatMessagesOptions(GetMessageKindError())
+	warningOptions.ConfigureWarning(true)
+	if formatted := err.(*BuildError).Format(warningOptions); !strings.Contains(formatted, "WARNING") {
+		t.Fatalf("expected the error to be labelled as a warning, got:\n%s", formatted)
+	}
+}
+
+func TestMetafile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "entry.js"), []byte(`import { greet } from "./greet"; greet()`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "greet.js"), []byte(`export function greet() { console.log("hello") }`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	options := NewBuildOptions()
+	options.ConfigureBundle(true)
+	options.ConfigureMetafile(true)
+	options.ConfigureAbsWorkingDir(dir)
+	options.ConfigureOutfile(filepath.Join(dir, "out.js"))
+	options.AddEntryPoint("entry.js")
+
+	result, err := BuildWithResult("", options)
+	if err != nil {
+		t.Fatal("build failed: ", err)
+	}
+
+	metafile, err := result.GetParsedMetafile()
+	if err != nil || metafile == nil {
+		t.Fatal("could not parse metafile: ", err)
+	}
+	if metafile.GetInputsCount() != 2 {
+		t.Fatalf("expected 2 inputs, got %d", metafile.GetInputsCount())
+	}
+	entry := metafile.GetInputByPath("entry.js")
+	if entry == nil || entry.GetImportsCount() != 1 || entry.GetImport(0).GetPath() != "greet.js" {
+		t.Fatalf("unexpected entry input: %+v", entry)
+	}
+	if metafile.GetEntryPointsCount() != 1 || metafile.GetEntryPoint(0) != "entry.js" {
+		t.Fatal("expected entry.js entry point")
+	}
+	if metafile.GetOutput(0).GetBytesInOutput("greet.js") == 0 {
+		t.Fatal("expected greet.js to contribute bytes to the output")
+	}
+
+	analysis := AnalyzeMetafile(result.GetMetafile(), nil)
+	if !strings.Contains(analysis, "greet.js") {
+		t.Fatalf("unexpected analysis:\n%s", analysis)
+	}
+
+	options.ConfigureMetafile(false)
+	if result, err = BuildWithResult("", options); err != nil {
+		t.Fatal("build failed: ", err)
+	}
+	if _, err := result.GetParsedMetafile(); err == nil || !strings.Contains(err.Error(), "no metafile was produced") {
+		t.Fatalf("expected an error without a metafile, got %v", err)
+	}
+}
+
+func TestTransformBatch(t *testing.T) {
+	batch := NewTransformBatch()
+	batch.ConfigureMaxConcurrency(3)
+	for i := 0; i < 20; i++ {
+		batch.AddInput(fmt.Sprintf("export const value%d = <b>{%d}</b>", i, i), fmt.Sprintf("snippet%d.jsx", i), 0)
+	}
+	batch.AddInput("const x: number = 1", "typed.ts", GetLoaderTS())
+	batch.AddInput("const broken = <div>", "broken.jsx", 0)
+
+	options := NewTransformOptions()
+	options.ConfigureJSXFactory("h")
+
+	result, err := TransformJSXBatch(batch, options)
+	if err != nil {
+		t.Fatal("batch failed: ", err)
+	}
+	if result.GetResultsCount() != 22 {
+		t.Fatalf("expected 22 results, got %d", result.GetResultsCount())
+	}
+	for i := 0; i < 20; i++ {
+		code := result.GetResult(i).GetCode()
+		if !strings.Contains(code, fmt.Sprintf("value%d = /* @__PURE__ */ h(", i)) {
+			t.Fatalf("result %d is out of order or untransformed:\n%s", i, code)
+		}
+	}
+	if result.GetResult(20).GetCode() != "const x = 1;\n" {
+		t.Fatalf("expected the ts loader to be used, got %q", result.GetResult(20).GetCode())
+	}
+	if result.GetFailedCount() != 1 || result.GetResult(21).GetError(0).GetLocation().GetFile() != "broken.jsx" {
+		t.Fatal("expected only the broken input to fail")
+	}
+
+	// The cancel handle applies to the batch as a whole
+	handle := NewCancelHandle()
+	handle.Cancel()
+	options.ConfigureCancelHandle(handle)
+	if _, err := TransformJSXBatch(batch, options); !errors.Is(err, ErrBuildCancelled) {
+		t.Fatalf("expected ErrBuildCancelled from the batch, got %v", err)
+	}
+
+	if result, err := TransformJSXBatch(nil, nil); err != nil || result.GetResultsCount() != 0 {
+		t.Fatalf("expected no results for a nil batch, got %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package esbuildmobile
+
+import (
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// MessageKind selects how messages are labelled when formatted
+type MessageKind uint8
+
+const (
+	MessageKindError MessageKind = iota
+	MessageKindWarning
+)
+
+// FormatMessagesOptions contains options for formatting messages
+// Documentation: https://esbuild.github.io/api/#format-messages
+type FormatMessagesOptions struct {
+	Kind          MessageKind
+	TerminalWidth int
+	Color         bool
+}
+
+func (k MessageKind) ToAPI() api.MessageKind {
+	switch k {
+	case MessageKindWarning:
+		return api.WarningMessage
+	default:
+		return api.ErrorMessage
+	}
+}
+
+func (o *FormatMessagesOptions) ToAPI() api.FormatMessagesOptions {
+	return api.FormatMessagesOptions{
+		Kind:          o.Kind.ToAPI(),
+		TerminalWidth: o.TerminalWidth,
+		Color:         o.Color,
+	}
+}
+
+// Getter functions for constants (gomobile friendly). gomobile cannot bind
+// MessageKind, so kinds are passed to it as int.
+
+func GetMessageKindError() int   { return int(MessageKindError) }
+func GetMessageKindWarning() int { return int(MessageKindWarning) }
+
+// NewFormatMessagesOptions creates options for formatting messages of the
+// given kind, GetMessageKindError() or GetMessageKindWarning()
+func NewFormatMessagesOptions(kind int) *FormatMessagesOptions {
+	return &FormatMessagesOptions{Kind: MessageKind(kind)}
+}
+
+func (o *FormatMessagesOptions) ConfigureKind(kind int)           { o.Kind = MessageKind(kind) }
+func (o *FormatMessagesOptions) ConfigureTerminalWidth(width int) { o.TerminalWidth = width }
+func (o *FormatMessagesOptions) ConfigureColor(color bool)        { o.Color = color }
+
+// ConfigureWarning labels messages as warnings instead of errors
+func (o *FormatMessagesOptions) ConfigureWarning(warning bool) {
+	o.Kind = MessageKindError
+	if warning {
+		o.Kind = MessageKindWarning
+	}
+}
+
+func (o *FormatMessagesOptions) GetKind() int    { return int(o.Kind) }
+func (o *FormatMessagesOptions) IsWarning() bool { return o.Kind == MessageKindWarning }
+
+// FormatMessages renders messages the same way the esbuild CLI does, with
+// a code frame and caret for every message that has a location.
+// `FormatMessagesOptions` is optional and defaults to errors without color.
+// gomobile cannot bind slices of messages; mobile callers use FormatMessage
+// or the Format methods of results and errors.
+func FormatMessages(messages []*Message, options *FormatMessagesOptions) []string {
+	if options == nil {
+		options = &FormatMessagesOptions{Kind: MessageKindError}
+	}
+	return api.FormatMessages(messagesToAPI(messages), options.ToAPI())
+}
+
+// formatMessagesJoined renders messages into a single string for mobile callers
+func formatMessagesJoined(messages []*Message, options *FormatMessagesOptions) string {
+	return strings.Join(FormatMessages(messages, options), "")
+}
+
+// FormatMessage renders a single message
+// `FormatMessagesOptions` is optional
+func FormatMessage(message *Message, options *FormatMessagesOptions) string {
+	if message == nil {
+		return ""
+	}
+	return formatMessagesJoined([]*Message{message}, options)
+}
+
+// Format renders every message of the error
+func (e *BuildError) Format(options *FormatMessagesOptions) string {
+	return formatMessagesJoined(e.Messages, options)
+}
+
+// FormatErrors renders every error of the transform
+func (r *TransformResult) FormatErrors(options *FormatMessagesOptions) string {
+	return formatMessagesJoined(r.Errors, withKind(options, MessageKindError))
+}
+
+// FormatWarnings renders every warning of the transform
+func (r *TransformResult) FormatWarnings(options *FormatMessagesOptions) string {
+	return formatMessagesJoined(r.Warnings, withKind(options, MessageKindWarning))
+}
+
+// FormatErrors renders every error of the build
+func (r *BuildResult) FormatErrors(options *FormatMessagesOptions) string {
+	return formatMessagesJoined(r.Errors, withKind(options, MessageKindError))
+}
+
+// FormatWarnings renders every warning of the build
+func (r *BuildResult) FormatWarnings(options *FormatMessagesOptions) string {
+	return formatMessagesJoined(r.Warnings, withKind(options, MessageKindWarning))
+}
+
+// withKind returns a copy of the options using the given kind
+func withKind(options *FormatMessagesOptions, kind MessageKind) *FormatMessagesOptions {
+	result := &FormatMessagesOptions{Kind: kind}
+	if options != nil {
+		result.TerminalWidth = options.TerminalWidth
+		result.Color = options.Color
+	}
+	return result
+}
@@ -0,0 +1,270 @@
+package esbuildmobile
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// ImportMap remaps import specifiers before they are resolved, following
+// the WICG import maps specification. Keys ending in "/" remap every
+// specifier starting with them, and scopes apply only to importers whose
+// path or URL starts with the scope.
+// Documentation: https://github.com/WICG/import-maps
+//
+// Relative keys and addresses are resolved against "/", the root of the
+// VirtualFS. An empty address blocks the specifier.
+type ImportMap struct {
+	Imports map[string]string
+	Scopes  map[string]map[string]string
+}
+
+// JSON shape of an import map, where a null address blocks a specifier
+type importMapJSON struct {
+	Imports map[string]*string            `json:"imports"`
+	Scopes  map[string]map[string]*string `json:"scopes"`
+}
+
+// NewImportMap creates an empty import map
+func NewImportMap() *ImportMap {
+	return &ImportMap{
+		Imports: make(map[string]string),
+		Scopes:  make(map[string]map[string]string),
+	}
+}
+
+// ParseImportMap parses an import map from its JSON text
+func ParseImportMap(text string) (*ImportMap, error) {
+	var raw importMapJSON
+	if err := json.Unmarshal([]byte(text), &raw); err != nil {
+		return nil, err
+	}
+
+	importMap := NewImportMap()
+	for specifier, address := range raw.Imports {
+		if err := importMap.addImport("", specifier, address); err != nil {
+			return nil, err
+		}
+	}
+	for scope, imports := range raw.Scopes {
+		if err := importMap.addImport(scope, "", nil); err != nil {
+			return nil, err
+		}
+		for specifier, address := range imports {
+			if err := importMap.addImport(scope, specifier, address); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return importMap, nil
+}
+
+// AddImport maps a specifier, or a prefix ending in "/", to an address
+func (m *ImportMap) AddImport(specifier string, address string) error {
+	return m.addImport("", specifier, &address)
+}
+
+// AddScopedImport maps a specifier for importers inside a scope only
+func (m *ImportMap) AddScopedImport(scope string, specifier string, address string) error {
+	return m.addImport(scope, specifier, &address)
+}
+
+// BlockImport makes importing a specifier an error
+func (m *ImportMap) BlockImport(specifier string) error {
+	return m.addImport("", specifier, nil)
+}
+
+// addImport normalizes and stores a mapping. An empty specifier only creates the scope.
+func (m *ImportMap) addImport(scope string, specifier string, address *string) error {
+	imports := m.Imports
+	if scope != "" {
+		normalizedScope, ok := normalizeImportMapURL(scope)
+		if !ok {
+			return fmt.Errorf("invalid import map scope %q", scope)
+		}
+		if imports = m.Scopes[normalizedScope]; imports == nil {
+			imports = make(map[string]string)
+			m.Scopes[normalizedScope] = imports
+		}
+	}
+	if specifier == "" {
+		return nil
+	}
+
+	key := specifier
+	if normalized, ok := normalizeImportMapURL(specifier); ok {
+		key = normalized
+	}
+	if address == nil || *address == "" {
+		imports[key] = ""
+		return nil
+	}
+	normalizedAddress, ok := normalizeImportMapURL(*address)
+	if !ok {
+		return fmt.Errorf("invalid address %q for %q in the import map: addresses must be URLs or paths", *address, specifier)
+	}
+	if strings.HasSuffix(key, "/") && !strings.HasSuffix(normalizedAddress, "/") {
+		return fmt.Errorf("invalid address %q for %q in the import map: it must end with \"/\"", *address, specifier)
+	}
+	imports[key] = normalizedAddress
+	return nil
+}
+
+var urlSchemeRegexp = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+
+// normalizeImportMapURL resolves URL-like strings (URLs and paths starting
+// with "/", "./" or "../") against "/". It returns false for bare specifiers.
+func normalizeImportMapURL(specifier string) (string, bool) {
+	if urlSchemeRegexp.MatchString(specifier) {
+		return specifier, true
+	}
+	if isPathImport(specifier) {
+		return joinImportPath("/", specifier), true
+	}
+	return "", false
+}
+
+// joinImportPath resolves a relative specifier against a directory,
+// keeping a trailing "/"
+func joinImportPath(dir string, specifier string) string {
+	joined := path.Join(dir, specifier)
+	if strings.HasSuffix(specifier, "/") && !strings.HasSuffix(joined, "/") {
+		joined += "/"
+	}
+	return joined
+}
+
+// resolve returns the address for a specifier imported from importer, which
+// is a path or URL. It returns false if the import map does not apply.
+func (m *ImportMap) resolve(specifier string, importer string) (string, bool, error) {
+	if importer == "" || importer == "<stdin>" {
+		importer = "/"
+	}
+
+	normalized := specifier
+	switch {
+	case urlSchemeRegexp.MatchString(specifier):
+	case isPathImport(specifier):
+		if base, err := url.Parse(importer); err == nil && base.IsAbs() {
+			reference, err := url.Parse(specifier)
+			if err != nil {
+				return "", false, nil
+			}
+			normalized = base.ResolveReference(reference).String()
+		} else {
+			normalized = joinImportPath(path.Dir(importer), specifier)
+		}
+	}
+
+	for _, scope := range sortedImportMapKeys(m.Scopes) {
+		if scope == importer || (strings.HasSuffix(scope, "/") && strings.HasPrefix(importer, scope)) {
+			if address, ok, err := resolveImportsMatch(specifier, normalized, m.Scopes[scope]); ok || err != nil {
+				return address, ok, err
+			}
+		}
+	}
+	return resolveImportsMatch(specifier, normalized, m.Imports)
+}
+
+// resolveImportsMatch finds the longest key matching the normalized specifier
+func resolveImportsMatch(specifier string, normalized string, imports map[string]string) (string, bool, error) {
+	for _, key := range sortedImportMapKeys(imports) {
+		address := imports[key]
+		var rest string
+		switch {
+		case key == normalized:
+		case strings.HasSuffix(key, "/") && strings.HasPrefix(normalized, key):
+			rest = normalized[len(key):]
+		default:
+			continue
+		}
+		if address == "" {
+			return "", false, fmt.Errorf("Import %q is blocked by the import map", specifier)
+		}
+		return address + rest, true, nil
+	}
+	return "", false, nil
+}
+
+// sortedImportMapKeys sorts keys in descending order, so longer prefixes are tried first
+func sortedImportMapKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+	return keys
+}
+
+// Getter methods for ImportMap
+
+func (m *ImportMap) GetImportsCount() int { return len(m.Imports) }
+func (m *ImportMap) GetScopesCount() int  { return len(m.Scopes) }
+
+// GetImport returns the address of a top-level mapping, or "" if there is none
+func (m *ImportMap) GetImport(specifier string) string { return m.Imports[specifier] }
+
+// ResolveSpecifier returns the address a specifier is remapped to when
+// imported from importer, or "" if the import map does not apply
+func (m *ImportMap) ResolveSpecifier(specifier string, importer string) string {
+	address, _, _ := m.resolve(specifier, importer)
+	return address
+}
+
+// importMapResolved marks the nested resolve of a remapped specifier, so
+// the import map is not applied to its own output
+type importMapResolved struct{}
+
+// apiPlugin applies the import map before any other plugin, then hands the
+// address back to esbuild so that other plugins can resolve it
+func (m *ImportMap) apiPlugin() api.Plugin {
+	return api.Plugin{
+		Name: "esbuildmobile-import-map",
+		Setup: func(build api.PluginBuild) {
+			build.OnResolve(api.OnResolveOptions{Filter: FilterAllFiles}, func(args api.OnResolveArgs) (api.OnResolveResult, error) {
+				if _, ok := args.PluginData.(importMapResolved); ok || args.Kind == api.ResolveEntryPoint {
+					return api.OnResolveResult{}, nil
+				}
+
+				address, ok, err := m.resolve(args.Path, args.Importer)
+				if err != nil {
+					return api.OnResolveResult{Errors: []api.Message{{Text: err.Error()}}}, nil
+				}
+				if !ok {
+					return api.OnResolveResult{}, nil
+				}
+
+				result := build.Resolve(address, api.ResolveOptions{
+					Importer:   args.Importer,
+					Namespace:  args.Namespace,
+					ResolveDir: args.ResolveDir,
+					Kind:       args.Kind,
+					PluginData: importMapResolved{},
+					With:       args.With,
+				})
+				if len(result.Errors) > 0 {
+					return api.OnResolveResult{Errors: result.Errors, Warnings: result.Warnings}, nil
+				}
+				sideEffects := api.SideEffectsTrue
+				if !result.SideEffects {
+					sideEffects = api.SideEffectsFalse
+				}
+				return api.OnResolveResult{
+					Warnings:    result.Warnings,
+					Path:        result.Path,
+					External:    result.External,
+					SideEffects: sideEffects,
+					Namespace:   result.Namespace,
+					Suffix:      result.Suffix,
+					PluginData:  result.PluginData,
+				}, nil
+			})
+		},
+	}
+}
@@ -0,0 +1,61 @@
+package esbuildmobile
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestImportMap(t *testing.T) {
+	importMap, err := ParseImportMap(`{
+		"imports": {
+			"react": "/vendor/react.js",
+			"lib/": "./vendor/lib/",
+			"blocked": null
+		},
+		"scopes": {
+			"/legacy/": {"react": "/vendor/react-legacy.js"}
+		}
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address := importMap.ResolveSpecifier("lib/a/b.js", "/src/index.js"); address != "/vendor/lib/a/b.js" {
+		t.Fatalf("unexpected prefix mapping %q", address)
+	}
+	if address := importMap.ResolveSpecifier("react", "/legacy/app.js"); address != "/vendor/react-legacy.js" {
+		t.Fatalf("unexpected scoped mapping %q", address)
+	}
+
+	fs := NewVirtualFS()
+	fs.AddFile("/vendor/react.js", []byte(`export default "react"`))
+	fs.AddFile("/vendor/react-legacy.js", []byte(`export default "legacy react"`))
+	fs.AddFile("/vendor/lib/util.js", []byte(`export default "util"`))
+	fs.AddFile("/legacy/app.js", []byte(`export { default } from "react"`))
+	fs.AddFile("/src/index.js", []byte(`import react from "react"; import legacy from "../legacy/app.js"; import util from "lib/util.js"; console.log(react, legacy, util)`))
+
+	options := NewBuildOptions()
+	options.ConfigureBundle(true)
+	options.ConfigureVirtualFS(fs)
+	options.ConfigureImportMap(importMap)
+	options.AddEntryPoint("/src/index.js")
+
+	code, err := Build("", options)
+	if err != nil {
+		t.Fatal("build failed: ", err)
+	}
+	for _, expected := range []string{`"react"`, `"legacy react"`, `"util"`} {
+		if !strings.Contains(code, expected) {
+			t.Fatalf("expected %s in output:\n%s", expected, code)
+		}
+	}
+
+	options.EntryPoints = nil
+	_, err = Build(`import "blocked"`, options)
+	if err == nil || !strings.Contains(err.Error(), "blocked by the import map") {
+		t.Fatalf("expected blocked import error, got %v", err)
+	}
+
+	if _, err := ParseImportMap(`{"imports": {"a": "bare"}}`); err == nil {
+		t.Fatal("expected an error for a bare address")
+	}
+}
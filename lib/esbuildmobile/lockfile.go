@@ -0,0 +1,229 @@
+package esbuildmobile
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// lockfileVersion is the version of the lockfile format
+const lockfileVersion = 1
+
+// Lockfile pins every URL import to the URL it resolved to and a SHA-384
+// integrity hash of its contents. Attach it with URLImports.ConfigureLockfile.
+//
+// URLs are recorded the first time they are fetched and verified on every
+// later build, including when they come from the content cache. A frozen
+// lockfile also rejects URLs it does not list, so builds can never pick up
+// new remote code.
+type Lockfile struct {
+	mutex   sync.Mutex
+	path    string
+	frozen  bool
+	entries map[string]*LockfileEntry
+}
+
+// LockfileEntry is the pinned result of a URL import
+type LockfileEntry struct {
+	URL       string `json:"url"`       // final URL after redirects
+	Integrity string `json:"integrity"` // "sha384-" followed by the base64 digest
+}
+
+// JSON shape of a lockfile
+type lockfileJSON struct {
+	Version int                       `json:"version"`
+	Remote  map[string]*LockfileEntry `json:"remote"`
+}
+
+// NewLockfile creates an empty lockfile kept in memory. Use GetJSON to save it.
+func NewLockfile() *Lockfile {
+	return &Lockfile{entries: make(map[string]*LockfileEntry)}
+}
+
+// ParseLockfile reads a lockfile from the JSON returned by GetJSON
+func ParseLockfile(text string) (*Lockfile, error) {
+	var raw lockfileJSON
+	if err := json.Unmarshal([]byte(text), &raw); err != nil {
+		return nil, err
+	}
+	if raw.Version != lockfileVersion {
+		return nil, fmt.Errorf("unsupported lockfile version %d", raw.Version)
+	}
+	lockfile := NewLockfile()
+	for rawURL, entry := range raw.Remote {
+		if entry == nil || entry.URL == "" || entry.Integrity == "" {
+			return nil, fmt.Errorf("invalid lockfile entry for %s", rawURL)
+		}
+		lockfile.entries[rawURL] = entry
+	}
+	return lockfile, nil
+}
+
+// OpenLockfile reads the lockfile at path, or creates an empty one if the
+// file does not exist. New entries are written to the file as they are recorded.
+func OpenLockfile(path string) (*Lockfile, error) {
+	lockfile := NewLockfile()
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if lockfile, err = ParseLockfile(string(data)); err != nil {
+			return nil, fmt.Errorf("could not read lockfile %s: %w", path, err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	lockfile.path = path
+	return lockfile, nil
+}
+
+// ConfigureFrozen makes imports of URLs missing from the lockfile an error
+func (l *Lockfile) ConfigureFrozen(frozen bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.frozen = frozen
+}
+
+// integrityOf returns the SRI hash of contents
+func integrityOf(contents []byte) string {
+	sum := sha512.Sum384(contents)
+	return "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// checkListed returns an error if the lockfile is frozen and does not list rawURL
+func (l *Lockfile) checkListed(rawURL string, importer string) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if _, ok := l.entries[rawURL]; !ok && l.frozen {
+		return fmt.Errorf("%s imported by %s is not listed in the lockfile", rawURL, importer)
+	}
+	return nil
+}
+
+// verify checks a fetched module against the lockfile, recording it if it
+// is not listed yet. The error names the URL and the module importing it.
+func (l *Lockfile) verify(rawURL string, importer string, module *remoteModule) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	integrity := integrityOf(module.Contents)
+	entry, ok := l.entries[rawURL]
+	if !ok {
+		if l.frozen {
+			return fmt.Errorf("%s imported by %s is not listed in the lockfile", rawURL, importer)
+		}
+		l.entries[rawURL] = &LockfileEntry{URL: module.URL, Integrity: integrity}
+		return l.save()
+	}
+	if entry.URL != module.URL {
+		return fmt.Errorf("%s imported by %s resolved to %s, but the lockfile expects %s", rawURL, importer, module.URL, entry.URL)
+	}
+	if entry.Integrity != integrity {
+		return fmt.Errorf("Integrity check failed for %s imported by %s: the lockfile expects %s, but the contents have %s", rawURL, importer, entry.Integrity, integrity)
+	}
+	return nil
+}
+
+// save writes the lockfile to its path, if it has one. The caller holds the mutex.
+func (l *Lockfile) save() error {
+	if l.path == "" {
+		return nil
+	}
+	data, err := l.marshal()
+	if err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(l.path), ".lockfile-")
+	if err != nil {
+		return err
+	}
+	_, err = temp.Write(data)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), l.path)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return fmt.Errorf("could not write lockfile %s: %w", l.path, err)
+	}
+	return nil
+}
+
+func (l *Lockfile) marshal() ([]byte, error) {
+	data, err := json.MarshalIndent(&lockfileJSON{Version: lockfileVersion, Remote: l.entries}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// hash returns a hash of every entry, used as the cache key of builds
+func (l *Lockfile) hash() string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	data, _ := l.marshal()
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Getter methods for Lockfile
+
+// GetJSON returns the lockfile encoded as JSON, for storing it
+func (l *Lockfile) GetJSON() string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	data, err := l.marshal()
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func (l *Lockfile) GetPath() string { return l.path }
+
+func (l *Lockfile) IsFrozen() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.frozen
+}
+
+func (l *Lockfile) GetEntriesCount() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return len(l.entries)
+}
+
+// GetEntryURL returns a locked URL, with URLs sorted
+func (l *Lockfile) GetEntryURL(index int) string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	urls := make([]string, 0, len(l.entries))
+	for rawURL := range l.entries {
+		urls = append(urls, rawURL)
+	}
+	sort.Strings(urls)
+	if index >= 0 && index < len(urls) {
+		return urls[index]
+	}
+	return ""
+}
+
+// GetEntry returns the entry of a locked URL, or nil
+func (l *Lockfile) GetEntry(rawURL string) *LockfileEntry {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.entries[rawURL]
+}
+
+// Getter methods for LockfileEntry
+
+func (e *LockfileEntry) GetURL() string       { return e.URL }
+func (e *LockfileEntry) GetIntegrity() string { return e.Integrity }
@@ -0,0 +1,66 @@
+package esbuildmobile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLockfile(t *testing.T) {
+	contents := `export default "v1"`
+	var fetched []string
+	fetcher := NewSwiftFetcher()
+	fetcher.SetHandler(func(url string) (*FetchResponse, error) {
+		fetched = append(fetched, url)
+		return NewFetchResponse([]byte(contents), "text/javascript"), nil
+	})
+	lockfilePath := filepath.Join(t.TempDir(), "esbuild.lock.json")
+
+	build := func(input string, frozen bool) error {
+		lockfile, err := OpenLockfile(lockfilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lockfile.ConfigureFrozen(frozen)
+		imports := NewURLImports(fetcher)
+		imports.ConfigureLockfile(lockfile)
+
+		options := NewBuildOptions()
+		options.ConfigureBundle(true)
+		options.ConfigureURLImports(imports)
+		_, err = Build(input, options)
+		return err
+	}
+
+	if err := build(`import "https://cdn.test/a.js"`, false); err != nil {
+		t.Fatal("build failed: ", err)
+	}
+	data, err := os.ReadFile(lockfilePath)
+	if err != nil {
+		t.Fatal("expected the lockfile to be written: ", err)
+	}
+	lockfile, err := ParseLockfile(string(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := lockfile.GetEntry("https://cdn.test/a.js")
+	if entry == nil || entry.Integrity != integrityOf([]byte(contents)) {
+		t.Fatalf("unexpected lockfile:\n%s", data)
+	}
+
+	contents = `export default "v2"`
+	err = build(`import "https://cdn.test/a.js"`, false)
+	if err == nil || !strings.Contains(err.Error(), "Integrity check failed for https://cdn.test/a.js imported by <stdin>") {
+		t.Fatalf("expected an integrity error, got %v", err)
+	}
+
+	fetched = nil
+	err = build(`import "https://cdn.test/b.js"`, true)
+	if err == nil || !strings.Contains(err.Error(), "https://cdn.test/b.js imported by <stdin> is not listed in the lockfile") {
+		t.Fatalf("expected an unlisted URL error, got %v", err)
+	}
+	if len(fetched) != 0 {
+		t.Fatalf("expected a frozen lockfile to reject the URL before fetching it, got %v", fetched)
+	}
+}
@@ -0,0 +1,217 @@
+package esbuildmobile
+
+import "github.com/evanw/esbuild/pkg/api"
+
+// Message represents an error or warning (mobile-friendly wrapper for api.Message)
+type Message struct {
+	ID         string
+	PluginName string
+	Text       string
+	Location   *Location
+	Notes      []*Note
+}
+
+// Location represents the position of a message in a source file
+type Location struct {
+	File       string
+	Namespace  string
+	Line       int // 1-based
+	Column     int // 0-based, in bytes
+	Length     int // in bytes
+	LineText   string
+	Suggestion string
+}
+
+// Note represents additional information attached to a message
+type Note struct {
+	Text     string
+	Location *Location
+}
+
+// Constructor functions
+
+func NewMessage(text string) *Message {
+	return &Message{
+		Text:  text,
+		Notes: make([]*Note, 0),
+	}
+}
+
+// NewMessageWithLocation creates a message pointing at a file, line and column
+func NewMessageWithLocation(text string, file string, line int, column int) *Message {
+	message := NewMessage(text)
+	message.Location = NewLocation(file, line, column)
+	return message
+}
+
+func NewLocation(file string, line int, column int) *Location {
+	return &Location{
+		File:   file,
+		Line:   line,
+		Column: column,
+	}
+}
+
+func NewNote(text string, location *Location) *Note {
+	return &Note{
+		Text:     text,
+		Location: location,
+	}
+}
+
+// Conversion functions to/from API types
+
+func (m *Message) ToAPI() api.Message {
+	notes := make([]api.Note, 0, len(m.Notes))
+	for _, note := range m.Notes {
+		if note != nil {
+			notes = append(notes, note.ToAPI())
+		}
+	}
+	return api.Message{
+		ID:         m.ID,
+		PluginName: m.PluginName,
+		Text:       m.Text,
+		Location:   m.Location.ToAPI(),
+		Notes:      notes,
+	}
+}
+
+func MessageFromAPI(m api.Message) *Message {
+	notes := make([]*Note, len(m.Notes))
+	for i, note := range m.Notes {
+		notes[i] = NoteFromAPI(note)
+	}
+	return &Message{
+		ID:         m.ID,
+		PluginName: m.PluginName,
+		Text:       m.Text,
+		Location:   LocationFromAPI(m.Location),
+		Notes:      notes,
+	}
+}
+
+// ToAPI converts the location, returning nil for a nil location
+func (l *Location) ToAPI() *api.Location {
+	if l == nil {
+		return nil
+	}
+	return &api.Location{
+		File:       l.File,
+		Namespace:  l.Namespace,
+		Line:       l.Line,
+		Column:     l.Column,
+		Length:     l.Length,
+		LineText:   l.LineText,
+		Suggestion: l.Suggestion,
+	}
+}
+
+// LocationFromAPI converts an api.Location, returning nil for a nil location
+func LocationFromAPI(l *api.Location) *Location {
+	if l == nil {
+		return nil
+	}
+	return &Location{
+		File:       l.File,
+		Namespace:  l.Namespace,
+		Line:       l.Line,
+		Column:     l.Column,
+		Length:     l.Length,
+		LineText:   l.LineText,
+		Suggestion: l.Suggestion,
+	}
+}
+
+func (n *Note) ToAPI() api.Note {
+	return api.Note{
+		Text:     n.Text,
+		Location: n.Location.ToAPI(),
+	}
+}
+
+func NoteFromAPI(n api.Note) *Note {
+	return &Note{
+		Text:     n.Text,
+		Location: LocationFromAPI(n.Location),
+	}
+}
+
+// messagesFromAPI converts a list of api.Message, never returning nil
+func messagesFromAPI(messages []api.Message) []*Message {
+	result := make([]*Message, len(messages))
+	for i, message := range messages {
+		result[i] = MessageFromAPI(message)
+	}
+	return result
+}
+
+// messagesToAPI converts a list of messages, skipping nil entries
+func messagesToAPI(messages []*Message) []api.Message {
+	if len(messages) == 0 {
+		return nil
+	}
+	result := make([]api.Message, 0, len(messages))
+	for _, message := range messages {
+		if message != nil {
+			result = append(result, message.ToAPI())
+		}
+	}
+	return result
+}
+
+// getMessage returns the message at index, or nil when out of range
+func getMessage(messages []*Message, index int) *Message {
+	if index >= 0 && index < len(messages) {
+		return messages[index]
+	}
+	return nil
+}
+
+// Configuration methods for Message
+
+func (m *Message) ConfigureID(id string)                  { m.ID = id }
+func (m *Message) ConfigurePluginName(name string)        { m.PluginName = name }
+func (m *Message) ConfigureLocation(location *Location)   { m.Location = location }
+func (m *Message) ConfigureText(text string)              { m.Text = text }
+func (m *Message) AddNote(note *Note)                     { m.Notes = append(m.Notes, note) }
+func (m *Message) AddNoteText(text string, loc *Location) { m.AddNote(NewNote(text, loc)) }
+
+// Getter methods for Message
+
+func (m *Message) GetID() string          { return m.ID }
+func (m *Message) GetPluginName() string  { return m.PluginName }
+func (m *Message) GetText() string        { return m.Text }
+func (m *Message) GetLocation() *Location { return m.Location }
+func (m *Message) HasLocation() bool      { return m.Location != nil }
+func (m *Message) GetNotesCount() int     { return len(m.Notes) }
+
+func (m *Message) GetNote(index int) *Note {
+	if index >= 0 && index < len(m.Notes) {
+		return m.Notes[index]
+	}
+	return nil
+}
+
+// Configuration methods for Location
+
+func (l *Location) ConfigureNamespace(namespace string)   { l.Namespace = namespace }
+func (l *Location) ConfigureLength(length int)            { l.Length = length }
+func (l *Location) ConfigureLineText(lineText string)     { l.LineText = lineText }
+func (l *Location) ConfigureSuggestion(suggestion string) { l.Suggestion = suggestion }
+
+// Getter methods for Location
+
+func (l *Location) GetFile() string       { return l.File }
+func (l *Location) GetNamespace() string  { return l.Namespace }
+func (l *Location) GetLine() int          { return l.Line }
+func (l *Location) GetColumn() int        { return l.Column }
+func (l *Location) GetLength() int        { return l.Length }
+func (l *Location) GetLineText() string   { return l.LineText }
+func (l *Location) GetSuggestion() string { return l.Suggestion }
+
+// Getter methods for Note
+
+func (n *Note) GetText() string        { return n.Text }
+func (n *Note) GetLocation() *Location { return n.Location }
+func (n *Note) HasLocation() bool      { return n.Location != nil }
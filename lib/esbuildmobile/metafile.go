@@ -0,0 +1,337 @@
+package esbuildmobile
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// Metafile is a parsed view of the JSON metafile produced when
+// BuildOptions.Metafile is enabled
+// Documentation: https://esbuild.github.io/api/#metafile
+type Metafile struct {
+	Inputs  []*MetafileInput
+	Outputs []*MetafileOutput
+}
+
+// MetafileInput describes a single source file that was part of the build
+type MetafileInput struct {
+	Path    string
+	Bytes   int
+	Format  string
+	Imports []*MetafileImport
+}
+
+// MetafileImport describes an import record of an input or output file
+type MetafileImport struct {
+	Path     string
+	Kind     string
+	External bool
+	Original string
+}
+
+// MetafileOutput describes a single file emitted by the build
+type MetafileOutput struct {
+	Path       string
+	Bytes      int
+	EntryPoint string
+	CSSBundle  string
+	Inputs     []*MetafileOutputInput
+	Imports    []*MetafileImport
+	Exports    []string
+}
+
+// MetafileOutputInput describes how many bytes an input contributed to an output
+type MetafileOutputInput struct {
+	Path          string
+	BytesInOutput int
+}
+
+// AnalyzeMetafileOptions contains options for AnalyzeMetafile
+type AnalyzeMetafileOptions struct {
+	Color   bool
+	Verbose bool
+}
+
+// JSON shape of the metafile
+type metafileJSON struct {
+	Inputs  map[string]metafileInputJSON  `json:"inputs"`
+	Outputs map[string]metafileOutputJSON `json:"outputs"`
+}
+
+type metafileInputJSON struct {
+	Bytes   int                  `json:"bytes"`
+	Format  string               `json:"format"`
+	Imports []metafileImportJSON `json:"imports"`
+}
+
+type metafileImportJSON struct {
+	Path     string `json:"path"`
+	Kind     string `json:"kind"`
+	External bool   `json:"external"`
+	Original string `json:"original"`
+}
+
+type metafileOutputJSON struct {
+	Bytes      int                                `json:"bytes"`
+	EntryPoint string                             `json:"entryPoint"`
+	CSSBundle  string                             `json:"cssBundle"`
+	Inputs     map[string]metafileOutputInputJSON `json:"inputs"`
+	Imports    []metafileImportJSON               `json:"imports"`
+	Exports    []string                           `json:"exports"`
+}
+
+type metafileOutputInputJSON struct {
+	BytesInOutput int `json:"bytesInOutput"`
+}
+
+// ParseMetafile parses the JSON metafile returned by a build.
+// Inputs and outputs are sorted by path.
+func ParseMetafile(metafile string) (*Metafile, error) {
+	var raw metafileJSON
+	if err := json.Unmarshal([]byte(metafile), &raw); err != nil {
+		return nil, err
+	}
+
+	result := &Metafile{
+		Inputs:  make([]*MetafileInput, 0, len(raw.Inputs)),
+		Outputs: make([]*MetafileOutput, 0, len(raw.Outputs)),
+	}
+
+	for _, path := range sortedKeys(raw.Inputs) {
+		input := raw.Inputs[path]
+		result.Inputs = append(result.Inputs, &MetafileInput{
+			Path:    path,
+			Bytes:   input.Bytes,
+			Format:  input.Format,
+			Imports: metafileImportsFromJSON(input.Imports),
+		})
+	}
+
+	for _, path := range sortedKeys(raw.Outputs) {
+		output := raw.Outputs[path]
+		inputs := make([]*MetafileOutputInput, 0, len(output.Inputs))
+		for _, inputPath := range sortedKeys(output.Inputs) {
+			inputs = append(inputs, &MetafileOutputInput{
+				Path:          inputPath,
+				BytesInOutput: output.Inputs[inputPath].BytesInOutput,
+			})
+		}
+		result.Outputs = append(result.Outputs, &MetafileOutput{
+			Path:       path,
+			Bytes:      output.Bytes,
+			EntryPoint: output.EntryPoint,
+			CSSBundle:  output.CSSBundle,
+			Inputs:     inputs,
+			Imports:    metafileImportsFromJSON(output.Imports),
+			Exports:    output.Exports,
+		})
+	}
+
+	return result, nil
+}
+
+func metafileImportsFromJSON(imports []metafileImportJSON) []*MetafileImport {
+	result := make([]*MetafileImport, len(imports))
+	for i, record := range imports {
+		result[i] = &MetafileImport{
+			Path:     record.Path,
+			Kind:     record.Kind,
+			External: record.External,
+			Original: record.Original,
+		}
+	}
+	return result
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// GetParsedMetafile parses the metafile of the build. It returns an error
+// if BuildOptions.Metafile was not enabled; check with HasMetafile first.
+func (r *BuildResult) GetParsedMetafile() (*Metafile, error) {
+	if r.Metafile == "" {
+		return nil, errors.New("no metafile was produced, enable BuildOptions.Metafile")
+	}
+	return ParseMetafile(r.Metafile)
+}
+
+// NewAnalyzeMetafileOptions creates AnalyzeMetafileOptions with default values
+func NewAnalyzeMetafileOptions() *AnalyzeMetafileOptions {
+	return &AnalyzeMetafileOptions{}
+}
+
+func (o *AnalyzeMetafileOptions) ConfigureColor(color bool)     { o.Color = color }
+func (o *AnalyzeMetafileOptions) ConfigureVerbose(verbose bool) { o.Verbose = verbose }
+
+func (o *AnalyzeMetafileOptions) ToAPI() api.AnalyzeMetafileOptions {
+	return api.AnalyzeMetafileOptions{
+		Color:   o.Color,
+		Verbose: o.Verbose,
+	}
+}
+
+// AnalyzeMetafile returns a human-readable summary of the bundle contents
+// `AnalyzeMetafileOptions` is optional
+// Documentation: https://esbuild.github.io/api/#analyze
+func AnalyzeMetafile(metafile string, options *AnalyzeMetafileOptions) string {
+	if options == nil {
+		options = NewAnalyzeMetafileOptions()
+	}
+	return api.AnalyzeMetafile(metafile, options.ToAPI())
+}
+
+// Getter methods for Metafile
+
+func (m *Metafile) GetInputsCount() int  { return len(m.Inputs) }
+func (m *Metafile) GetOutputsCount() int { return len(m.Outputs) }
+
+func (m *Metafile) GetInput(index int) *MetafileInput {
+	if index >= 0 && index < len(m.Inputs) {
+		return m.Inputs[index]
+	}
+	return nil
+}
+
+func (m *Metafile) GetOutput(index int) *MetafileOutput {
+	if index >= 0 && index < len(m.Outputs) {
+		return m.Outputs[index]
+	}
+	return nil
+}
+
+func (m *Metafile) GetInputByPath(path string) *MetafileInput {
+	for _, input := range m.Inputs {
+		if input.Path == path {
+			return input
+		}
+	}
+	return nil
+}
+
+func (m *Metafile) GetOutputByPath(path string) *MetafileOutput {
+	for _, output := range m.Outputs {
+		if output.Path == path {
+			return output
+		}
+	}
+	return nil
+}
+
+// GetEntryPointsCount returns the number of outputs that were generated for an entry point
+func (m *Metafile) GetEntryPointsCount() int {
+	count := 0
+	for _, output := range m.Outputs {
+		if output.EntryPoint != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// GetEntryPoint returns the entry point path of the nth entry point output
+func (m *Metafile) GetEntryPoint(index int) string {
+	for _, output := range m.Outputs {
+		if output.EntryPoint == "" {
+			continue
+		}
+		if index == 0 {
+			return output.EntryPoint
+		}
+		index--
+	}
+	return ""
+}
+
+// GetTotalInputBytes returns the size of all inputs
+func (m *Metafile) GetTotalInputBytes() int {
+	total := 0
+	for _, input := range m.Inputs {
+		total += input.Bytes
+	}
+	return total
+}
+
+// GetTotalOutputBytes returns the size of all outputs
+func (m *Metafile) GetTotalOutputBytes() int {
+	total := 0
+	for _, output := range m.Outputs {
+		total += output.Bytes
+	}
+	return total
+}
+
+// Getter methods for MetafileInput
+
+func (i *MetafileInput) GetPath() string      { return i.Path }
+func (i *MetafileInput) GetBytes() int        { return i.Bytes }
+func (i *MetafileInput) GetFormat() string    { return i.Format }
+func (i *MetafileInput) GetImportsCount() int { return len(i.Imports) }
+
+func (i *MetafileInput) GetImport(index int) *MetafileImport {
+	if index >= 0 && index < len(i.Imports) {
+		return i.Imports[index]
+	}
+	return nil
+}
+
+// Getter methods for MetafileImport
+
+func (i *MetafileImport) GetPath() string     { return i.Path }
+func (i *MetafileImport) GetKind() string     { return i.Kind }
+func (i *MetafileImport) GetExternal() bool   { return i.External }
+func (i *MetafileImport) GetOriginal() string { return i.Original }
+
+// Getter methods for MetafileOutput
+
+func (o *MetafileOutput) GetPath() string       { return o.Path }
+func (o *MetafileOutput) GetBytes() int         { return o.Bytes }
+func (o *MetafileOutput) GetEntryPoint() string { return o.EntryPoint }
+func (o *MetafileOutput) GetCSSBundle() string  { return o.CSSBundle }
+func (o *MetafileOutput) GetInputsCount() int   { return len(o.Inputs) }
+func (o *MetafileOutput) GetImportsCount() int  { return len(o.Imports) }
+func (o *MetafileOutput) GetExportsCount() int  { return len(o.Exports) }
+
+func (o *MetafileOutput) GetInput(index int) *MetafileOutputInput {
+	if index >= 0 && index < len(o.Inputs) {
+		return o.Inputs[index]
+	}
+	return nil
+}
+
+func (o *MetafileOutput) GetImport(index int) *MetafileImport {
+	if index >= 0 && index < len(o.Imports) {
+		return o.Imports[index]
+	}
+	return nil
+}
+
+func (o *MetafileOutput) GetExport(index int) string {
+	if index >= 0 && index < len(o.Exports) {
+		return o.Exports[index]
+	}
+	return ""
+}
+
+// GetBytesInOutput returns how many bytes the given input contributed to this output
+func (o *MetafileOutput) GetBytesInOutput(inputPath string) int {
+	for _, input := range o.Inputs {
+		if input.Path == inputPath {
+			return input.BytesInOutput
+		}
+	}
+	return 0
+}
+
+// Getter methods for MetafileOutputInput
+
+func (i *MetafileOutputInput) GetPath() string       { return i.Path }
+func (i *MetafileOutputInput) GetBytesInOutput() int { return i.BytesInOutput }
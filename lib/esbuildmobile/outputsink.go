@@ -0,0 +1,208 @@
+package esbuildmobile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// OutputSink receives every file emitted by a successful build. Attach it
+// with BuildOptions.ConfigureOutputSink.
+//
+// Paths are relative to the output directory, which is Outdir, the
+// directory of Outfile, or the working directory when neither is set, and
+// always use "/" as the separator. Output files are still returned in the
+// BuildResult, and are written again when a build comes from a Cache.
+//
+// Unless AllowOverwrite is set, a build fails instead of writing an output
+// file over one of its input files, as it does with Write. The file written
+// is the one below the directory of a DirectoryOutputSink, and the output
+// path for other sinks.
+type OutputSink interface {
+	WriteFile(path string, contents []byte) error
+}
+
+// SwiftOutputSink is a concrete implementation that can be used from Swift
+type SwiftOutputSink struct {
+	handler func(string, []byte) error
+}
+
+// NewSwiftOutputSink creates an output sink that executes a handler
+func NewSwiftOutputSink() *SwiftOutputSink {
+	return &SwiftOutputSink{}
+}
+
+// SetHandler sets the handler function
+func (s *SwiftOutputSink) SetHandler(handler func(string, []byte) error) {
+	s.handler = handler
+}
+
+// WriteFile implements the OutputSink interface
+func (s *SwiftOutputSink) WriteFile(path string, contents []byte) error {
+	if s.handler == nil {
+		return errors.New("no output sink handler was set")
+	}
+	return s.handler(path, contents)
+}
+
+// DirectoryOutputSink writes output files below a directory on disk,
+// creating subdirectories as needed. Files are written atomically and
+// replace existing files, the same way as with Write.
+type DirectoryOutputSink struct {
+	dir string
+}
+
+// NewDirectoryOutputSink creates a sink that writes below dir
+func NewDirectoryOutputSink(dir string) *DirectoryOutputSink {
+	return &DirectoryOutputSink{dir: dir}
+}
+
+// WriteFile implements the OutputSink interface
+func (s *DirectoryOutputSink) WriteFile(filePath string, contents []byte) error {
+	if !filepath.IsLocal(filepath.FromSlash(filePath)) {
+		return fmt.Errorf("cannot write %q outside of %s", filePath, s.dir)
+	}
+	target := filepath.Join(s.dir, filepath.FromSlash(filePath))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(target), ".output-")
+	if err != nil {
+		return err
+	}
+	_, err = temp.Write(contents)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(temp.Name(), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), target)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+	return nil
+}
+
+// Getter methods for DirectoryOutputSink
+
+func (s *DirectoryOutputSink) GetDir() string { return s.dir }
+
+// VirtualFSOutputSink writes output files into a VirtualFS, below a
+// directory, replacing existing files
+type VirtualFSOutputSink struct {
+	fs  *VirtualFS
+	dir string
+}
+
+// NewVirtualFSOutputSink creates a sink that writes below dir in fs.
+// An empty dir writes to the root.
+func NewVirtualFSOutputSink(fs *VirtualFS, dir string) *VirtualFSOutputSink {
+	return &VirtualFSOutputSink{fs: fs, dir: cleanVirtualPath(dir)}
+}
+
+// WriteFile implements the OutputSink interface
+func (s *VirtualFSOutputSink) WriteFile(filePath string, contents []byte) error {
+	if !filepath.IsLocal(filepath.FromSlash(filePath)) {
+		return fmt.Errorf("cannot write %q outside of %s", filePath, s.dir)
+	}
+	s.fs.AddFile(path.Join(s.dir, filePath), contents)
+	return nil
+}
+
+// Getter methods for VirtualFSOutputSink
+
+func (s *VirtualFSOutputSink) GetFS() *VirtualFS { return s.fs }
+func (s *VirtualFSOutputSink) GetDir() string    { return s.dir }
+
+// outputDir returns the absolute directory output paths are made relative to
+func (b *BuildOptions) outputDir() string {
+	workingDir := b.AbsWorkingDir
+	if workingDir == "" {
+		workingDir, _ = os.Getwd()
+	}
+	dir := workingDir
+	switch {
+	case b.Outdir != "":
+		dir = b.Outdir
+	case b.Outfile != "":
+		dir = filepath.Dir(b.Outfile)
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(workingDir, dir)
+	}
+	return dir
+}
+
+// outputTarget returns the absolute path an output file is written to
+func outputTarget(sink OutputSink, file *OutputFile, relative string) string {
+	if directory, ok := sink.(*DirectoryOutputSink); ok {
+		if target, err := filepath.Abs(filepath.Join(directory.dir, filepath.FromSlash(relative))); err == nil {
+			return target
+		}
+	}
+	return file.Path
+}
+
+// writeOutputFiles passes every output file to the OutputSink, returning
+// an error message for each file that could not be written. inputs maps the
+// absolute paths of the input files of the build to their paths in the
+// metafile; they are not overwritten unless AllowOverwrite is set.
+func (b *BuildOptions) writeOutputFiles(files []*OutputFile, inputs map[string]string) []*Message {
+	outputDir := b.outputDir()
+	var messages []*Message
+	for _, file := range files {
+		relative, err := filepath.Rel(outputDir, file.Path)
+		if !filepath.IsAbs(file.Path) {
+			err = errors.New("set Outfile or Outdir to name the files written to an OutputSink")
+		} else if err == nil && !filepath.IsLocal(relative) {
+			err = fmt.Errorf("the file is outside of the output directory %s", outputDir)
+		}
+		if err == nil && !b.AllowOverwrite {
+			if input, ok := inputs[outputTarget(b.OutputSink, file, relative)]; ok {
+				messages = append(messages, NewMessage(fmt.Sprintf("Refusing to overwrite input file %q (use \"AllowOverwrite: true\" to allow this)", input)))
+				continue
+			}
+		}
+		if err == nil {
+			err = b.OutputSink.WriteFile(filepath.ToSlash(relative), file.Contents)
+		}
+		if err != nil {
+			messages = append(messages, NewMessage(fmt.Sprintf("Failed to write %q: %v", file.Path, err)))
+		}
+	}
+	return messages
+}
+
+// outputSinkPlugin writes the output files of every successful build to the
+// OutputSink. It is registered after the user plugins so their OnEnd
+// callbacks have already run. The input files are read from the metafile,
+// which toAPIBuildOptionsWithInput enables unless AllowOverwrite is set.
+func (b *BuildOptions) outputSinkPlugin() api.Plugin {
+	return api.Plugin{
+		Name: "esbuildmobile-output-sink",
+		Setup: func(build api.PluginBuild) {
+			build.OnEnd(func(result *api.BuildResult) (api.OnEndResult, error) {
+				if len(result.Errors) > 0 {
+					return api.OnEndResult{}, nil
+				}
+				var inputs map[string]string
+				if !b.AllowOverwrite {
+					var ok bool
+					if inputs, ok = metafileInputFiles(result.Metafile, b.AbsWorkingDir); !ok {
+						return api.OnEndResult{Errors: messagesToAPI([]*Message{NewMessage("Failed to list the input files of the build")})}, nil
+					}
+				}
+				messages := b.writeOutputFiles(buildResultFromAPI(result).OutputFiles, inputs)
+				return api.OnEndResult{Errors: messagesToAPI(messages)}, nil
+			})
+		},
+	}
+}
@@ -0,0 +1,106 @@
+package esbuildmobile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestOutputSink(t *testing.T) {
+	dir := t.TempDir()
+	outputDir := filepath.Join(dir, "container")
+
+	options := NewBuildOptions()
+	options.ConfigureAbsWorkingDir(dir)
+	options.ConfigureOutdir("dist")
+	options.ConfigureSourcemap(api.SourceMapLinked)
+	options.ConfigureOutputSink(NewDirectoryOutputSink(outputDir))
+
+	result, err := BuildWithResult(`console.log("sink")`, options)
+	if err != nil {
+		t.Fatal("build failed: ", err)
+	}
+	if len(result.OutputFiles) != 2 {
+		t.Fatalf("expected the output files in the result, got %d", len(result.OutputFiles))
+	}
+	for _, name := range []string{"stdin.js", "stdin.js.map"} {
+		if _, err := os.Stat(filepath.Join(outputDir, name)); err != nil {
+			t.Fatalf("expected %s to be written: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "dist")); err == nil {
+		t.Fatal("expected nothing to be written to the outdir")
+	}
+
+	// A new sink for the same directory replaces the earlier outputs, as after a relaunch
+	options.ConfigureOutputSink(NewDirectoryOutputSink(outputDir))
+	if _, err := BuildWithResult(`console.log("replaced")`, options); err != nil {
+		t.Fatal("rebuild failed: ", err)
+	}
+	if contents, _ := os.ReadFile(filepath.Join(outputDir, "stdin.js")); !strings.Contains(string(contents), `"replaced"`) {
+		t.Fatalf("expected the output to be replaced, got %q", contents)
+	}
+
+	fs := NewVirtualFS()
+	options.ConfigureOutputSink(NewVirtualFSOutputSink(fs, "/bundles"))
+	if _, err := BuildWithResult(`console.log("virtual")`, options); err != nil {
+		t.Fatal("build failed: ", err)
+	}
+	if !strings.Contains(string(fs.GetFile("/bundles/stdin.js")), `"virtual"`) || !fs.HasFile("/bundles/stdin.js.map") {
+		t.Fatalf("expected the output files in the virtual file system, got %d files", fs.GetFilesCount())
+	}
+}
+
+func TestOutputSinkAllowOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	source := `console.log("source")`
+	if err := os.WriteFile(filepath.Join(dir, "app.js"), []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	options := NewBuildOptions()
+	options.ConfigureAbsWorkingDir(dir)
+	options.ConfigureOutdir("dist")
+	options.AddEntryPoint("app.js")
+	options.ConfigureLogLevel(api.LogLevelSilent)
+	options.ConfigureOutputSink(NewDirectoryOutputSink(dir))
+
+	result, err := BuildWithResult("", options)
+	if err == nil || !strings.Contains(err.Error(), `Refusing to overwrite input file "app.js"`) {
+		t.Fatalf("expected an overwrite error, got %v", err)
+	}
+	if contents, _ := os.ReadFile(filepath.Join(dir, "app.js")); string(contents) != source {
+		t.Fatalf("expected the input file to be kept, got %q", contents)
+	}
+	if result.HasMetafile() {
+		t.Fatal("expected no metafile in the result unless Metafile is set")
+	}
+
+	options.ConfigureAllowOverwrite(true)
+	if _, err := BuildWithResult("", options); err != nil {
+		t.Fatal("build failed: ", err)
+	}
+	if contents, _ := os.ReadFile(filepath.Join(dir, "app.js")); string(contents) == source {
+		t.Fatal("expected AllowOverwrite to replace the input file")
+	}
+}
+
+func TestBuildWrite(t *testing.T) {
+	dir := t.TempDir()
+
+	options := NewBuildOptions()
+	options.ConfigureAbsWorkingDir(dir)
+	options.ConfigureOutfile("out/bundle.js")
+	options.ConfigureWrite(true)
+
+	if _, err := Build(`console.log("written")`, options); err != nil {
+		t.Fatal("build failed: ", err)
+	}
+	contents, err := os.ReadFile(filepath.Join(dir, "out", "bundle.js"))
+	if err != nil || !strings.Contains(string(contents), `"written"`) {
+		t.Fatalf("expected Write to write the outfile, got %q, %v", contents, err)
+	}
+}
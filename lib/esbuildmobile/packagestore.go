@@ -0,0 +1,712 @@
+package esbuildmobile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// NamespacePackages is the namespace of every file loaded from a PackageStore
+const NamespacePackages = "npm"
+
+// disabledPathPrefix marks modules replaced with `false` by a "browser" field
+const disabledPathPrefix = "(disabled):"
+
+// PackageStore serves npm packages from in-memory tarballs, so builds can
+// bundle dependencies without a node_modules folder. Attach it with
+// BuildOptions.ConfigurePackageStore.
+//
+// Bare imports such as "react" or "@scope/pkg/sub" are resolved the way Node
+// does, honoring "exports" and "imports" with the Conditions option,
+// MainFields, subpath patterns and the "browser" field. Only one version of
+// each package can be stored.
+type PackageStore struct {
+	mutex    sync.RWMutex
+	fs       *VirtualFS
+	packages map[string]*storedPackage
+}
+
+type storedPackage struct {
+	name     string
+	version  string
+	root     string // "/node_modules/<name>"
+	manifest *orderedObject
+}
+
+// NewPackageStore creates an empty package store
+func NewPackageStore() *PackageStore {
+	return &PackageStore{
+		fs:       NewVirtualFS(),
+		packages: make(map[string]*storedPackage),
+	}
+}
+
+// AddPackage adds an npm tarball (.tgz), replacing any stored version of the
+// package. The name and version default to the ones in its package.json.
+func (s *PackageStore) AddPackage(name string, version string, tarball []byte) error {
+	archive, err := NewVirtualFSFromArchive(tarball)
+	if err != nil {
+		return err
+	}
+
+	// npm packs every file under a single directory, usually "package"
+	prefix, ok := packageArchivePrefix(archive)
+	if !ok {
+		return errors.New("the tarball does not contain a package.json file")
+	}
+	manifest, err := parseOrderedJSON(archive.GetFile(prefix + "/package.json"))
+	if err != nil {
+		return fmt.Errorf("could not parse package.json: %w", err)
+	}
+	object, ok := manifest.(*orderedObject)
+	if !ok {
+		return errors.New("package.json does not contain an object")
+	}
+	if name == "" {
+		name, _ = object.get("name").(string)
+	}
+	if version == "" {
+		version, _ = object.get("version").(string)
+	}
+	if _, subpath, ok := splitPackageSpecifier(name); !ok || subpath != "." {
+		return fmt.Errorf("invalid package name %q", name)
+	}
+
+	pkg := &storedPackage{
+		name:     name,
+		version:  version,
+		root:     "/node_modules/" + name,
+		manifest: object,
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.fs.removeTree(pkg.root)
+	for _, filePath := range archive.filePaths() {
+		if strings.HasPrefix(filePath, prefix+"/") {
+			s.fs.AddFile(pkg.root+strings.TrimPrefix(filePath, prefix), archive.GetFile(filePath))
+		}
+	}
+	s.packages[name] = pkg
+	return nil
+}
+
+// packageArchivePrefix finds the directory holding the top-level package.json
+func packageArchivePrefix(archive *VirtualFS) (string, bool) {
+	best := ""
+	for _, filePath := range archive.filePaths() {
+		if path.Base(filePath) != "package.json" {
+			continue
+		}
+		dir := path.Dir(filePath)
+		if best == "" || strings.Count(dir, "/") < strings.Count(best, "/") {
+			best = dir
+		}
+	}
+	if best == "/" {
+		return "", true
+	}
+	return best, best != ""
+}
+
+// RemovePackage removes a package, returning false if it was not stored
+func (s *PackageStore) RemovePackage(name string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	pkg, ok := s.packages[name]
+	if !ok {
+		return false
+	}
+	s.fs.removeTree(pkg.root)
+	delete(s.packages, name)
+	return true
+}
+
+// Getter methods for PackageStore
+
+func (s *PackageStore) HasPackage(name string) bool {
+	return s.getPackage(name) != nil
+}
+
+// GetPackageVersion returns the version of a stored package, or "" if it is not stored
+func (s *PackageStore) GetPackageVersion(name string) string {
+	if pkg := s.getPackage(name); pkg != nil {
+		return pkg.version
+	}
+	return ""
+}
+
+func (s *PackageStore) GetPackagesCount() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return len(s.packages)
+}
+
+// GetPackageName returns the name of a package, with packages sorted by name
+func (s *PackageStore) GetPackageName(index int) string {
+	s.mutex.RLock()
+	names := make([]string, 0, len(s.packages))
+	for name := range s.packages {
+		names = append(names, name)
+	}
+	s.mutex.RUnlock()
+
+	sort.Strings(names)
+	if index >= 0 && index < len(names) {
+		return names[index]
+	}
+	return ""
+}
+
+func (s *PackageStore) getPackage(name string) *storedPackage {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.packages[name]
+}
+
+// packageForPath returns the package containing a file of the store
+func (s *PackageStore) packageForPath(filePath string) *storedPackage {
+	rest, ok := strings.CutPrefix(filePath, "/node_modules/")
+	if !ok {
+		return nil
+	}
+	name, _, ok := splitPackageSpecifier(rest)
+	if !ok {
+		return nil
+	}
+	return s.getPackage(name)
+}
+
+// splitPackageSpecifier splits "@scope/name/sub/path" into the package name
+// and a subpath relative to the package ("./sub/path", or "." for the root)
+func splitPackageSpecifier(specifier string) (name string, subpath string, ok bool) {
+	parts := strings.SplitN(specifier, "/", 3)
+	name = parts[0]
+	if strings.HasPrefix(specifier, "@") {
+		if len(parts) < 2 || len(parts[0]) < 2 || parts[1] == "" {
+			return "", "", false
+		}
+		name = parts[0] + "/" + parts[1]
+	}
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `\%`) {
+		return "", "", false
+	}
+	return name, "." + specifier[len(name):], true
+}
+
+// matchesExternal reports whether a bare import matches the External option,
+// including "pkg/sub" for an external "pkg" and a single "*" wildcard
+func matchesExternal(specifier string, externals []string) bool {
+	for _, external := range externals {
+		if star := strings.IndexByte(external, '*'); star >= 0 {
+			prefix, suffix := external[:star], external[star+1:]
+			if len(specifier) >= len(prefix)+len(suffix) && strings.HasPrefix(specifier, prefix) && strings.HasSuffix(specifier, suffix) {
+				return true
+			}
+			continue
+		}
+		if specifier == external || strings.HasPrefix(specifier, external+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// hash returns a hash of every stored file, used as the cache key of builds
+func (s *PackageStore) hash() string {
+	return s.fs.hash()
+}
+
+// plugin serves the store to a build
+func (s *PackageStore) plugin(options *BuildOptions, withCacheKey bool) *Plugin {
+	plugin := NewPlugin("esbuildmobile-package-store")
+	if withCacheKey {
+		plugin.SetCacheKey(s.hash())
+	}
+
+	resolver := newPackageResolver(s, options)
+	plugin.OnResolve(CreateFilterForPath(FilterAllFiles), &packageResolveCallback{resolver: resolver})
+
+	loadOptions := CreateFilterForNamespace(NamespacePackages)
+	loadOptions.SetLoadFilter(FilterAllFiles)
+	plugin.OnLoad(loadOptions, &packageLoadCallback{store: s, loaders: options.Loader})
+	return plugin
+}
+
+// packageResolver holds the options that affect package resolution
+type packageResolver struct {
+	store       *PackageStore
+	extensions  []string
+	mainFields  []string
+	conditions  map[string]bool
+	browser     bool
+	external    []string
+	externalAll bool
+}
+
+func newPackageResolver(store *PackageStore, options *BuildOptions) *packageResolver {
+	r := &packageResolver{
+		store:       store,
+		extensions:  options.ResolveExtensions,
+		mainFields:  options.MainFields,
+		conditions:  make(map[string]bool),
+		browser:     options.Platform == api.PlatformDefault || options.Platform == api.PlatformBrowser,
+		external:    options.External,
+		externalAll: options.Packages == api.PackagesExternal,
+	}
+	if len(r.extensions) == 0 {
+		r.extensions = defaultResolveExtensions
+	}
+
+	// Same defaults as esbuild: https://esbuild.github.io/api/#main-fields
+	if len(r.mainFields) == 0 {
+		switch options.Platform {
+		case api.PlatformNode:
+			r.mainFields = []string{"main", "module"}
+		case api.PlatformNeutral:
+			r.mainFields = nil
+		default:
+			r.mainFields = []string{"browser", "module", "main"}
+		}
+	}
+
+	// Same defaults as esbuild: https://esbuild.github.io/api/#conditions
+	switch {
+	case r.browser:
+		r.conditions["browser"] = true
+	case options.Platform == api.PlatformNode:
+		r.conditions["node"] = true
+	}
+	if len(options.Conditions) == 0 {
+		r.conditions["module"] = true
+	}
+	for _, condition := range options.Conditions {
+		r.conditions[condition] = true
+	}
+	return r
+}
+
+// conditionsFor adds "import" or "require" depending on the kind of import
+func (r *packageResolver) conditionsFor(kind ResolveKind) map[string]bool {
+	conditions := make(map[string]bool, len(r.conditions)+2)
+	for condition := range r.conditions {
+		conditions[condition] = true
+	}
+	conditions["default"] = true
+	if kind == ResolveJSRequireCall || kind == ResolveJSRequireResolve {
+		conditions["require"] = true
+	} else {
+		conditions["import"] = true
+	}
+	return conditions
+}
+
+// packageResolution is a resolved file of the store, or a disabled module
+type packageResolution struct {
+	path     string
+	pkg      *storedPackage
+	disabled bool
+}
+
+type packageResolveCallback struct {
+	resolver *packageResolver
+}
+
+func (c *packageResolveCallback) Call(args *OnResolveArgs) *OnResolveResult {
+	result := NewOnResolveResult()
+	fromStore := args.Namespace == NamespacePackages
+	var importer *storedPackage
+	if fromStore {
+		importer = c.resolver.store.packageForPath(args.Importer)
+	}
+
+	var resolution *packageResolution
+	var err error
+	switch {
+	case isPathImport(args.Path):
+		if !fromStore {
+			return result
+		}
+		resolution, err = c.resolver.resolveRelative(importer, joinVirtualPath(args.ResolveDir, args.Path))
+	case strings.HasPrefix(args.Path, "#"):
+		if importer == nil {
+			return result
+		}
+		resolution, err = c.resolver.resolveImports(importer, args.Path, args.Kind)
+	default:
+		if c.resolver.externalAll || matchesExternal(args.Path, c.resolver.external) {
+			return result
+		}
+		resolution, err = c.resolver.resolveBare(importer, args.Path, args.Kind)
+	}
+
+	if err != nil {
+		result.AddResolveError(NewMessage(err.Error()))
+		return result
+	}
+	if resolution == nil {
+		// Let esbuild report imports from the store that nothing else resolves
+		if fromStore && !strings.HasPrefix(args.Path, "node:") {
+			result.AddResolveError(NewMessage(fmt.Sprintf("Could not resolve %q in the package store", args.Path)))
+		}
+		return result
+	}
+
+	result.Namespace = NamespacePackages
+	if resolution.disabled {
+		result.Path = disabledPathPrefix + args.Path
+		return result
+	}
+	result.Path = resolution.path
+	if sideEffects, ok := resolution.pkg.manifest.get("sideEffects").(bool); ok && !sideEffects {
+		result.SideEffects = SideEffectsFalse
+	}
+	return result
+}
+
+// resolveRelative resolves a path inside the package of the importer
+func (r *packageResolver) resolveRelative(pkg *storedPackage, filePath string) (*packageResolution, error) {
+	resolved, ok := r.store.fs.resolveFile(cleanVirtualPath(filePath), r.extensions)
+	if !ok || pkg == nil {
+		return nil, nil
+	}
+	return r.browserRemap(pkg, resolved), nil
+}
+
+// resolveImports resolves a "#name" import with the "imports" field of the importer's package
+func (r *packageResolver) resolveImports(pkg *storedPackage, specifier string, kind ResolveKind) (*packageResolution, error) {
+	imports, ok := pkg.manifest.get("imports").(*orderedObject)
+	if !ok {
+		return nil, fmt.Errorf("Package import specifier %q is not defined because %s has no \"imports\" field", specifier, pkg.root+"/package.json")
+	}
+	target, err := resolvePackageSubpath(imports, specifier, r.conditionsFor(kind), true)
+	if err != nil {
+		return nil, fmt.Errorf("Package import specifier %q is not defined by \"imports\" in %s", specifier, pkg.root+"/package.json")
+	}
+	if !strings.HasPrefix(target, "./") {
+		return r.resolveBare(pkg, target, kind)
+	}
+	return r.resolveTarget(pkg, target)
+}
+
+// resolveBare resolves a package name with an optional subpath
+func (r *packageResolver) resolveBare(importer *storedPackage, specifier string, kind ResolveKind) (*packageResolution, error) {
+	// The "browser" field of the importing package can replace whole packages
+	if importer != nil && r.browser {
+		if browserMap, ok := importer.manifest.get("browser").(*orderedObject); ok {
+			switch value := browserMap.get(specifier).(type) {
+			case bool:
+				if !value {
+					return &packageResolution{disabled: true}, nil
+				}
+			case string:
+				if isPathImport(value) {
+					return r.resolveRelative(importer, path.Join(importer.root, value))
+				}
+				specifier = value
+			}
+		}
+	}
+
+	name, subpath, ok := splitPackageSpecifier(specifier)
+	if !ok {
+		return nil, nil
+	}
+	pkg := r.store.getPackage(name)
+	if pkg == nil {
+		return nil, nil
+	}
+
+	if exports := pkg.manifest.get("exports"); exports != nil {
+		target, err := resolvePackageExports(exports, subpath, r.conditionsFor(kind))
+		if err != nil {
+			if subpath == "." {
+				return nil, fmt.Errorf("No \"exports\" main defined in %s", pkg.root+"/package.json")
+			}
+			return nil, fmt.Errorf("Package subpath %q is not defined by \"exports\" in %s", subpath, pkg.root+"/package.json")
+		}
+		return r.resolveTarget(pkg, target)
+	}
+
+	if subpath != "." {
+		resolved, ok := r.store.fs.resolveFile(pkg.root+subpath[1:], r.extensions)
+		if !ok {
+			return nil, fmt.Errorf("Could not resolve %q in package %s", subpath, name)
+		}
+		return r.browserRemap(pkg, resolved), nil
+	}
+
+	for _, field := range r.mainFields {
+		main, ok := pkg.manifest.get(field).(string)
+		if !ok || main == "" {
+			continue
+		}
+		if resolved, ok := r.store.fs.resolveFile(cleanVirtualPath(path.Join(pkg.root, main)), r.extensions); ok {
+			return r.browserRemap(pkg, resolved), nil
+		}
+	}
+	if resolved, ok := r.store.fs.resolveFile(pkg.root+"/index", r.extensions); ok {
+		return r.browserRemap(pkg, resolved), nil
+	}
+	return nil, fmt.Errorf("Could not find the main file of package %s", name)
+}
+
+// resolveTarget resolves a "./" target of "exports" or "imports", which must name an existing file
+func (r *packageResolver) resolveTarget(pkg *storedPackage, target string) (*packageResolution, error) {
+	filePath := cleanVirtualPath(path.Join(pkg.root, target))
+	if !strings.HasPrefix(filePath, pkg.root+"/") || !r.store.fs.HasFile(filePath) {
+		return nil, fmt.Errorf("The file %q does not exist in package %s", target, pkg.name)
+	}
+	return r.browserRemap(pkg, filePath), nil
+}
+
+// browserRemap applies the object form of the "browser" field to a resolved file
+func (r *packageResolver) browserRemap(pkg *storedPackage, filePath string) *packageResolution {
+	resolution := &packageResolution{path: filePath, pkg: pkg}
+	browserMap, ok := pkg.manifest.get("browser").(*orderedObject)
+	if !r.browser || !ok {
+		return resolution
+	}
+	for _, key := range browserMap.keys {
+		// Keys without a path or an extension name packages, see resolveBare
+		if !isPathImport(key) && path.Ext(key) == "" {
+			continue
+		}
+		keyPath, ok := r.store.fs.resolveFile(cleanVirtualPath(path.Join(pkg.root, key)), r.extensions)
+		if !ok || keyPath != filePath {
+			continue
+		}
+		switch value := browserMap.values[key].(type) {
+		case bool:
+			if !value {
+				return &packageResolution{pkg: pkg, disabled: true}
+			}
+		case string:
+			if replacement, ok := r.store.fs.resolveFile(cleanVirtualPath(path.Join(pkg.root, value)), r.extensions); ok {
+				resolution.path = replacement
+			}
+		}
+		break
+	}
+	return resolution
+}
+
+var (
+	// errPackageNoMatch means no condition or subpath matched, so the next alternative is tried
+	errPackageNoMatch = errors.New("no match")
+	// errPackageExcluded means the subpath is explicitly mapped to null
+	errPackageExcluded = errors.New("excluded")
+)
+
+// resolvePackageExports implements Node's PACKAGE_EXPORTS_RESOLVE, returning a "./" target
+func resolvePackageExports(exports interface{}, subpath string, conditions map[string]bool) (string, error) {
+	object, ok := exports.(*orderedObject)
+	if !ok || !object.hasSubpathKeys() {
+		// "exports" is the main export alone: a string, an array or conditions
+		if subpath != "." {
+			return "", errPackageNoMatch
+		}
+		return resolvePackageTarget(exports, "", conditions, false)
+	}
+	return resolvePackageSubpath(object, subpath, conditions, false)
+}
+
+// resolvePackageSubpath matches a subpath (or "#name" import) against the
+// keys of "exports" or "imports", including "*" patterns and the deprecated
+// trailing "/" folder mappings
+func resolvePackageSubpath(object *orderedObject, subpath string, conditions map[string]bool, allowBare bool) (string, error) {
+	if target, ok := object.values[subpath]; ok && !strings.Contains(subpath, "*") {
+		return resolvePackageTarget(target, "", conditions, allowBare)
+	}
+
+	bestKey, bestMatch := "", ""
+	for _, key := range object.keys {
+		star := strings.IndexByte(key, '*')
+		if star < 0 {
+			if strings.HasSuffix(key, "/") && strings.HasPrefix(subpath, key) && bestKey == "" {
+				bestKey, bestMatch = key, subpath[len(key):]
+			}
+			continue
+		}
+		if strings.Count(key, "*") != 1 {
+			continue
+		}
+		prefix, suffix := key[:star], key[star+1:]
+		if len(subpath) < len(key) || !strings.HasPrefix(subpath, prefix) || !strings.HasSuffix(subpath, suffix) {
+			continue
+		}
+		// Node prefers the longest prefix, then the longest key
+		bestStar := strings.IndexByte(bestKey, '*')
+		if bestStar < 0 || star > bestStar || (star == bestStar && len(key) > len(bestKey)) {
+			bestKey, bestMatch = key, subpath[len(prefix):len(subpath)-len(suffix)]
+		}
+	}
+	if bestKey == "" {
+		return "", errPackageNoMatch
+	}
+
+	target := object.values[bestKey]
+	if !strings.Contains(bestKey, "*") {
+		// Folder mappings append the rest of the subpath to the target
+		if folder, ok := target.(string); ok {
+			target = folder + "*"
+		}
+	}
+	return resolvePackageTarget(target, bestMatch, conditions, allowBare)
+}
+
+// resolvePackageTarget implements Node's PACKAGE_TARGET_RESOLVE
+func resolvePackageTarget(target interface{}, patternMatch string, conditions map[string]bool, allowBare bool) (string, error) {
+	switch target := target.(type) {
+	case string:
+		if !strings.HasPrefix(target, "./") && (!allowBare || isPathImport(target)) {
+			return "", fmt.Errorf("invalid package target %q", target)
+		}
+		return strings.ReplaceAll(target, "*", patternMatch), nil
+
+	case []interface{}:
+		err := errPackageNoMatch
+		for _, alternative := range target {
+			var resolved string
+			if resolved, err = resolvePackageTarget(alternative, patternMatch, conditions, allowBare); err == nil {
+				return resolved, nil
+			}
+		}
+		return "", err
+
+	case *orderedObject:
+		for _, condition := range target.keys {
+			if !conditions[condition] {
+				continue
+			}
+			resolved, err := resolvePackageTarget(target.values[condition], patternMatch, conditions, allowBare)
+			if err != errPackageNoMatch {
+				return resolved, err
+			}
+		}
+		return "", errPackageNoMatch
+
+	case nil:
+		return "", errPackageExcluded
+	}
+	return "", fmt.Errorf("invalid package target %v", target)
+}
+
+type packageLoadCallback struct {
+	store   *PackageStore
+	loaders map[string]api.Loader
+}
+
+func (c *packageLoadCallback) Call(args *OnLoadArgs) *OnLoadResult {
+	if strings.HasPrefix(args.Path, disabledPathPrefix) {
+		return CreateJSLoadResult("module.exports = {}")
+	}
+	contents := c.store.fs.GetFile(args.Path)
+	if contents == nil {
+		result := NewOnLoadResult()
+		result.AddLoadError(NewMessage(fmt.Sprintf("File %q was removed from the package store", args.Path)))
+		return result
+	}
+	loader, ok := loaderForImportAttributes(args.With)
+	if !ok {
+		loader, ok = loaderForPath(args.Path, c.loaders)
+	}
+	if !ok {
+		result := NewOnLoadResult()
+		result.AddLoadError(NewMessage(fmt.Sprintf("No loader is configured for %q files: %s", path.Ext(args.Path), args.Path)))
+		return result
+	}
+	return CreateLoadResultWithResolveDir(string(contents), loader, path.Dir(args.Path))
+}
+
+// orderedObject is a decoded JSON object that keeps the order of its keys,
+// which decides the priority of conditions in "exports" and "imports"
+type orderedObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (o *orderedObject) get(key string) interface{} {
+	if o == nil {
+		return nil
+	}
+	return o.values[key]
+}
+
+// hasSubpathKeys reports whether the keys are subpaths (".", "./x") rather than conditions
+func (o *orderedObject) hasSubpathKeys() bool {
+	for _, key := range o.keys {
+		if strings.HasPrefix(key, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseOrderedJSON decodes JSON like json.Unmarshal into an interface{},
+// except that objects are decoded as *orderedObject
+func parseOrderedJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	value, err := decodeOrderedJSON(decoder)
+	if err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, errors.New("unexpected data after the top-level value")
+	}
+	return value, nil
+}
+
+func decodeOrderedJSON(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return token, nil
+	}
+
+	switch delim {
+	case '{':
+		object := &orderedObject{values: make(map[string]interface{})}
+		for decoder.More() {
+			keyToken, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := keyToken.(string)
+			value, err := decodeOrderedJSON(decoder)
+			if err != nil {
+				return nil, err
+			}
+			if _, duplicate := object.values[key]; !duplicate {
+				object.keys = append(object.keys, key)
+			}
+			object.values[key] = value
+		}
+		_, err = decoder.Token()
+		return object, err
+
+	case '[':
+		array := make([]interface{}, 0)
+		for decoder.More() {
+			value, err := decodeOrderedJSON(decoder)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		_, err = decoder.Token()
+		return array, err
+	}
+	return nil, fmt.Errorf("unexpected %v", delim)
+}
@@ -0,0 +1,138 @@
+package esbuildmobile
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// npmTarball packs files under "package/" the same way `npm pack` does
+func npmTarball(t *testing.T, files map[string]string) []byte {
+	var data bytes.Buffer
+	gzipWriter := gzip.NewWriter(&data)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for name, contents := range files {
+		header := &tar.Header{Name: "package/" + name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(contents))}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		tarWriter.Write([]byte(contents))
+	}
+	tarWriter.Close()
+	gzipWriter.Close()
+	return data.Bytes()
+}
+
+func newTestPackageStore(t *testing.T) *PackageStore {
+	store := NewPackageStore()
+	packages := map[string]map[string]string{
+		"modern": {
+			"package.json": `{
+				"name": "modern",
+				"version": "2.0.0",
+				"sideEffects": false,
+				"exports": {
+					".": {"import": "./esm/index.js", "require": "./cjs/index.js"},
+					"./features/*": {"custom": "./features/*.custom.js", "default": "./features/*.js"},
+					"./features/private/*": null
+				},
+				"imports": {"#platform": {"node": "./platform-node.js", "default": "./platform-browser.js"}}
+			}`,
+			"esm/index.js":          `import platform from "#platform"; export const format = "esm " + platform`,
+			"cjs/index.js":          `exports.format = "cjs"`,
+			"features/a.js":         `export default "feature a"`,
+			"features/a.custom.js":  `export default "custom feature a"`,
+			"features/private/b.js": `export default "private"`,
+			"platform-node.js":      `export default "node"`,
+			"platform-browser.js":   `export default "browser"`,
+		},
+		"legacy": {
+			"package.json": `{
+				"name": "legacy",
+				"version": "1.0.0",
+				"main": "lib/main.js",
+				"browser": {"./lib/transport.js": "./lib/transport-browser.js", "fs": false}
+			}`,
+			"lib/main.js":              `const fs = require("fs"); module.exports = require("./transport") + require("@scope/util").name + typeof fs.readFileSync`,
+			"lib/transport.js":         `module.exports = "node transport"`,
+			"lib/transport-browser.js": `module.exports = "browser transport"`,
+		},
+		"@scope/util": {
+			"package.json": `{"name": "@scope/util", "version": "0.1.0"}`,
+			"index.js":     `exports.name = " util"`,
+		},
+	}
+	for name, files := range packages {
+		if err := store.AddPackage("", "", npmTarball(t, files)); err != nil {
+			t.Fatalf("could not add %s: %v", name, err)
+		}
+	}
+	return store
+}
+
+func buildWithPackages(t *testing.T, input string, configure func(*BuildOptions)) (string, error) {
+	options := NewBuildOptions()
+	options.ConfigureBundle(true)
+	options.ConfigurePackageStore(newTestPackageStore(t))
+	if configure != nil {
+		configure(options)
+	}
+	return Build(input, options)
+}
+
+func TestPackageStoreExports(t *testing.T) {
+	code, err := buildWithPackages(t, `import { format } from "modern"; import a from "modern/features/a"; console.log(format, a, require("modern").format)`, nil)
+	if err != nil {
+		t.Fatal("build failed: ", err)
+	}
+	for _, expected := range []string{`"esm " + platform_browser_default`, `"feature a"`, `exports.format = "cjs"`} {
+		if !strings.Contains(code, expected) {
+			t.Fatalf("expected %q in output:\n%s", expected, code)
+		}
+	}
+
+	code, err = buildWithPackages(t, `import a from "modern/features/a"; console.log(a)`, func(options *BuildOptions) {
+		options.Conditions = []string{"custom"}
+		options.Platform = api.PlatformNode
+	})
+	if err != nil {
+		t.Fatal("build failed: ", err)
+	}
+	if !strings.Contains(code, `"custom feature a"`) {
+		t.Fatalf("expected custom condition to win:\n%s", code)
+	}
+
+	_, err = buildWithPackages(t, `import b from "modern/features/private/b"`, nil)
+	if err == nil || !strings.Contains(err.Error(), `"./features/private/b" is not defined by "exports"`) {
+		t.Fatalf("expected excluded subpath error, got %v", err)
+	}
+}
+
+func TestPackageStoreMainAndBrowserFields(t *testing.T) {
+	code, err := buildWithPackages(t, `console.log(require("legacy"))`, nil)
+	if err != nil {
+		t.Fatal("build failed: ", err)
+	}
+	if !strings.Contains(code, `"browser transport"`) || strings.Contains(code, `"node transport"`) || !strings.Contains(code, `" util"`) {
+		t.Fatalf("expected browser remapping:\n%s", code)
+	}
+
+	code, err = buildWithPackages(t, `console.log(require("legacy"))`, func(options *BuildOptions) {
+		options.Platform = api.PlatformNode
+		options.External = []string{"fs"}
+	})
+	if err != nil {
+		t.Fatal("build failed: ", err)
+	}
+	if !strings.Contains(code, `"node transport"`) || !strings.Contains(code, `require("fs")`) {
+		t.Fatalf("expected node resolution:\n%s", code)
+	}
+
+	if _, err := buildWithPackages(t, `import "missing"`, nil); err == nil {
+		t.Fatal("expected an error for a package that is not stored")
+	}
+}
@@ -0,0 +1,109 @@
+package esbuildmobile
+
+import "sync"
+
+// PluginDataHandle is an opaque host object, such as a Swift class
+// instance, attached as PluginData. ReleasePluginData is called once for
+// every result it was attached to, when the build that received it ends.
+type PluginDataHandle interface {
+	ReleasePluginData()
+}
+
+// PluginData is a value a resolve callback attaches to its result and the
+// matching load callback gets back in its args. Data attached to a load
+// result is passed to the resolve callbacks of the imports in that file.
+// It holds a string, bytes or a PluginDataHandle.
+// Documentation: https://esbuild.github.io/plugins/#on-resolve-results
+//
+// Values are kept in a registry owned by the build and only a handle is
+// passed through esbuild, so every value is freed when the build ends.
+type PluginData struct {
+	text   *string
+	bytes  []byte
+	handle PluginDataHandle
+}
+
+// NewPluginDataString creates plugin data holding a string
+func NewPluginDataString(text string) *PluginData {
+	return &PluginData{text: &text}
+}
+
+// NewPluginDataBytes creates plugin data holding a copy of bytes
+func NewPluginDataBytes(bytes []byte) *PluginData {
+	return &PluginData{bytes: append(make([]byte, 0, len(bytes)), bytes...)}
+}
+
+// NewPluginDataHandle creates plugin data holding a host object
+func NewPluginDataHandle(handle PluginDataHandle) *PluginData {
+	return &PluginData{handle: handle}
+}
+
+// Getter methods for PluginData
+
+func (d *PluginData) IsString() bool { return d.text != nil }
+func (d *PluginData) IsBytes() bool  { return d.bytes != nil }
+func (d *PluginData) IsHandle() bool { return d.handle != nil }
+
+func (d *PluginData) GetString() string {
+	if d.text != nil {
+		return *d.text
+	}
+	return ""
+}
+
+func (d *PluginData) GetBytes() []byte            { return d.bytes }
+func (d *PluginData) GetHandle() PluginDataHandle { return d.handle }
+
+// pluginDataKey is the value passed through esbuild in place of PluginData
+type pluginDataKey uint64
+
+// pluginDataRegistry holds the PluginData of one build at a time. The
+// plugins of a build share one registry through their pluginHost, which
+// clears it when the build ends.
+type pluginDataRegistry struct {
+	mutex  sync.Mutex
+	next   pluginDataKey
+	values map[pluginDataKey]*PluginData
+}
+
+func newPluginDataRegistry() *pluginDataRegistry {
+	return &pluginDataRegistry{values: make(map[pluginDataKey]*PluginData)}
+}
+
+// store registers data and returns the value to pass to esbuild
+func (r *pluginDataRegistry) store(data *PluginData) interface{} {
+	if data == nil {
+		return nil
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.next++
+	r.values[r.next] = data
+	return r.next
+}
+
+// lookup returns the PluginData for a value from esbuild, or nil if it
+// was not stored by this registry
+func (r *pluginDataRegistry) lookup(value interface{}) *PluginData {
+	key, ok := value.(pluginDataKey)
+	if !ok {
+		return nil
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.values[key]
+}
+
+// clear frees every value, releasing host objects
+func (r *pluginDataRegistry) clear() {
+	r.mutex.Lock()
+	values := r.values
+	r.values = make(map[pluginDataKey]*PluginData)
+	r.mutex.Unlock()
+
+	for _, data := range values {
+		if data.handle != nil {
+			data.handle.ReleasePluginData()
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package esbuildmobile
+
+import (
+	"strings"
+	"testing"
+)
+
+type testPluginDataHandle struct {
+	name     string
+	released int
+}
+
+func (h *testPluginDataHandle) ReleasePluginData() { h.released++ }
+
+func TestPluginData(t *testing.T) {
+	handle := &testPluginDataHandle{name: "host object"}
+	var importerData string
+
+	resolveCallback := NewSwiftOnResolveCallback()
+	resolveCallback.SetHandler(func(args *OnResolveArgs) *OnResolveResult {
+		result := CreateNamespaceResolveResult(args.Path, NamespaceVirtual)
+		switch args.Path {
+		case "virtual:a":
+			result.SetResolvePluginData(NewPluginDataString("alpha"))
+		case "virtual:b":
+			if args.PluginData != nil && args.PluginData.IsBytes() {
+				importerData = string(args.PluginData.GetBytes())
+			}
+			result.SetResolvePluginData(NewPluginDataHandle(handle))
+		}
+		return result
+	})
+
+	loadCallback := NewSwiftOnLoadCallback()
+	loadCallback.SetHandler(func(args *OnLoadArgs) *OnLoadResult {
+		if args.PluginData == nil {
+			return CreateJSLoadResult(`export default "missing"`)
+		}
+		if args.PluginData.IsString() {
+			result := CreateJSLoadResult(`import b from "virtual:b"; export default "` + args.PluginData.GetString() + `" + b`)
+			result.SetLoadPluginData(NewPluginDataBytes([]byte("from a")))
+			return result
+		}
+		return CreateJSLoadResult(`export default "` + args.PluginData.GetHandle().(*testPluginDataHandle).name + `"`)
+	})
+
+	plugin := NewPlugin("plugin-data")
+	plugin.OnResolve(CreateFilterForPath("^virtual:"), resolveCallback)
+	loadOptions := CreateFilterForNamespace(NamespaceVirtual)
+	loadOptions.SetLoadFilter(FilterAllFiles)
+	plugin.OnLoad(loadOptions, loadCallback)
+
+	options := NewBuildOptions()
+	options.ConfigureBundle(true)
+	options.AddPlugin(plugin)
+
+	code, err := Build(`import a from "virtual:a"; console.log(a)`, options)
+	if err != nil {
+		t.Fatal("build failed: ", err)
+	}
+	if !strings.Contains(code, `"alpha" + `) || !strings.Contains(code, `"host object"`) {
+		t.Fatalf("expected plugin data in output:\n%s", code)
+	}
+	if importerData != "from a" {
+		t.Fatalf("expected load plugin data in the resolve args of imports, got %q", importerData)
+	}
+	if handle.released != 1 {
+		t.Fatalf("expected the handle to be released once when the build ended, got %d", handle.released)
+	}
+}
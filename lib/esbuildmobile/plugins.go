@@ -1,6 +1,10 @@
 package esbuildmobile
 
-import "github.com/evanw/esbuild/pkg/api"
+import (
+	"sort"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
 
 // Callback interfaces for mobile compatibility
 type OnResolveCallback interface {
@@ -19,13 +23,19 @@ type OnEndCallback interface {
 	Call(result *BuildResult) *OnEndResult
 }
 
+type OnDisposeCallback interface {
+	Call()
+}
+
 // Plugin represents an ESBuild plugin
 type Plugin struct {
-	name            string
-	onResolveRules  []onResolveRule
-	onLoadRules     []onLoadRule
-	onStartCallback OnStartCallback
-	onEndCallback   OnEndCallback
+	name               string
+	cacheKey           string
+	onResolveRules     []onResolveRule
+	onLoadRules        []onLoadRule
+	onStartCallbacks   []OnStartCallback
+	onEndCallbacks     []OnEndCallback
+	onDisposeCallbacks []OnDisposeCallback
 }
 
 type onResolveRule struct {
@@ -38,12 +48,6 @@ type onLoadRule struct {
 	Callback OnLoadCallback
 }
 
-// BuildResult represents the result of a build (simplified for mobile)
-type BuildResult struct {
-	Errors   []api.Message
-	Warnings []api.Message
-}
-
 // SideEffects represents the side effects setting for a module
 type SideEffects uint8
 
@@ -67,6 +71,20 @@ const (
 	ResolveCSSURLToken
 )
 
+// Resolve kinds as int, since gomobile cannot bind ResolveKind. They are
+// taken by ResolveOptions.SetKind and returned by OnResolveArgs.GetKind.
+const (
+	ResolveKindNone              = int(ResolveNone)
+	ResolveKindEntryPoint        = int(ResolveEntryPoint)
+	ResolveKindJSImportStatement = int(ResolveJSImportStatement)
+	ResolveKindJSRequireCall     = int(ResolveJSRequireCall)
+	ResolveKindJSDynamicImport   = int(ResolveJSDynamicImport)
+	ResolveKindJSRequireResolve  = int(ResolveJSRequireResolve)
+	ResolveKindCSSImportRule     = int(ResolveCSSImportRule)
+	ResolveKindCSSComposesFrom   = int(ResolveCSSComposesFrom)
+	ResolveKindCSSURLToken       = int(ResolveCSSURLToken)
+)
+
 // ResolveOptions contains options for resolving a module
 type ResolveOptions struct {
 	PluginName string
@@ -74,32 +92,33 @@ type ResolveOptions struct {
 	Namespace  string
 	ResolveDir string
 	Kind       ResolveKind
-	// Note: PluginData and With are omitted as they can't be exported to mobile
+	PluginData *PluginData       // Passed to the resolve callbacks of the path
+	With       map[string]string // Import attributes, such as {"type": "json"}
 }
 
 // ResolveResult contains the result of resolving a module
 type ResolveResult struct {
-	Errors   []api.Message
-	Warnings []api.Message
+	Errors   []*Message
+	Warnings []*Message
 
 	Path        string
 	External    bool
 	SideEffects bool
 	Namespace   string
 	Suffix      string
-	// Note: PluginData is omitted as it can't be exported to mobile
+	PluginData  *PluginData
 }
 
 // OnStartResult contains the result from an OnStart callback
 type OnStartResult struct {
-	Errors   []api.Message
-	Warnings []api.Message
+	Errors   []*Message
+	Warnings []*Message
 }
 
 // OnEndResult contains the result from an OnEnd callback
 type OnEndResult struct {
-	Errors   []api.Message
-	Warnings []api.Message
+	Errors   []*Message
+	Warnings []*Message
 }
 
 // OnResolveOptions contains options for the OnResolve callback
@@ -115,22 +134,24 @@ type OnResolveArgs struct {
 	Namespace  string
 	ResolveDir string
 	Kind       ResolveKind
-	// Note: PluginData and With are omitted as they can't be exported to mobile
+	PluginData *PluginData       // Attached by the load callback of the importer or passed to Resolve, or nil
+	With       map[string]string // Import attributes, such as {"type": "json"}
+	Resolver   *Resolver         // Resolves other paths through esbuild and every plugin
 }
 
 // OnResolveResult contains the result from an OnResolve callback
 type OnResolveResult struct {
 	PluginName string
 
-	Errors   []api.Message
-	Warnings []api.Message
+	Errors   []*Message
+	Warnings []*Message
 
 	Path        string
 	External    bool
 	SideEffects SideEffects
 	Namespace   string
 	Suffix      string
-	// Note: PluginData is omitted as it can't be exported to mobile
+	PluginData  *PluginData // Passed to the load callback of the module
 
 	WatchFiles []string
 	WatchDirs  []string
@@ -144,23 +165,25 @@ type OnLoadOptions struct {
 
 // OnLoadArgs contains arguments passed to the OnLoad callback
 type OnLoadArgs struct {
-	Path      string
-	Namespace string
-	Suffix    string
-	// Note: PluginData and With are omitted as they can't be exported to mobile
+	Path       string
+	Namespace  string
+	Suffix     string
+	PluginData *PluginData       // Attached by the resolve callback, or nil
+	With       map[string]string // Import attributes, such as {"type": "json"}
+	Resolver   *Resolver         // Resolves other paths through esbuild and every plugin
 }
 
 // OnLoadResult contains the result from an OnLoad callback
 type OnLoadResult struct {
 	PluginName string
 
-	Errors   []api.Message
-	Warnings []api.Message
+	Errors   []*Message
+	Warnings []*Message
 
 	Contents   *string
 	ResolveDir string
 	Loader     api.Loader
-	// Note: PluginData is omitted as it can't be exported to mobile
+	PluginData *PluginData // Passed to the resolve callbacks of the imports in the module
 
 	WatchFiles []string
 	WatchDirs  []string
@@ -273,15 +296,15 @@ func OnLoadOptionsFromAPI(o api.OnLoadOptions) *OnLoadOptions {
 func GetSideEffectsTrue() SideEffects  { return SideEffectsTrue }
 func GetSideEffectsFalse() SideEffects { return SideEffectsFalse }
 
-func GetResolveNone() ResolveKind              { return ResolveNone }
-func GetResolveEntryPoint() ResolveKind        { return ResolveEntryPoint }
-func GetResolveJSImportStatement() ResolveKind { return ResolveJSImportStatement }
-func GetResolveJSRequireCall() ResolveKind     { return ResolveJSRequireCall }
-func GetResolveJSDynamicImport() ResolveKind   { return ResolveJSDynamicImport }
-func GetResolveJSRequireResolve() ResolveKind  { return ResolveJSRequireResolve }
-func GetResolveCSSImportRule() ResolveKind     { return ResolveCSSImportRule }
-func GetResolveCSSComposesFrom() ResolveKind   { return ResolveCSSComposesFrom }
-func GetResolveCSSURLToken() ResolveKind       { return ResolveCSSURLToken }
+func GetResolveNone() int              { return ResolveKindNone }
+func GetResolveEntryPoint() int        { return ResolveKindEntryPoint }
+func GetResolveJSImportStatement() int { return ResolveKindJSImportStatement }
+func GetResolveJSRequireCall() int     { return ResolveKindJSRequireCall }
+func GetResolveJSDynamicImport() int   { return ResolveKindJSDynamicImport }
+func GetResolveJSRequireResolve() int  { return ResolveKindJSRequireResolve }
+func GetResolveCSSImportRule() int     { return ResolveKindCSSImportRule }
+func GetResolveCSSComposesFrom() int   { return ResolveKindCSSComposesFrom }
+func GetResolveCSSURLToken() int       { return ResolveKindCSSURLToken }
 
 // Constructor functions
 
@@ -295,8 +318,8 @@ func NewOnLoadOptions() *OnLoadOptions {
 
 func NewOnResolveResult() *OnResolveResult {
 	return &OnResolveResult{
-		Errors:     make([]api.Message, 0),
-		Warnings:   make([]api.Message, 0),
+		Errors:     make([]*Message, 0),
+		Warnings:   make([]*Message, 0),
 		WatchFiles: make([]string, 0),
 		WatchDirs:  make([]string, 0),
 	}
@@ -304,8 +327,8 @@ func NewOnResolveResult() *OnResolveResult {
 
 func NewOnLoadResult() *OnLoadResult {
 	return &OnLoadResult{
-		Errors:     make([]api.Message, 0),
-		Warnings:   make([]api.Message, 0),
+		Errors:     make([]*Message, 0),
+		Warnings:   make([]*Message, 0),
 		WatchFiles: make([]string, 0),
 		WatchDirs:  make([]string, 0),
 	}
@@ -313,15 +336,15 @@ func NewOnLoadResult() *OnLoadResult {
 
 func NewOnStartResult() *OnStartResult {
 	return &OnStartResult{
-		Errors:   make([]api.Message, 0),
-		Warnings: make([]api.Message, 0),
+		Errors:   make([]*Message, 0),
+		Warnings: make([]*Message, 0),
 	}
 }
 
 func NewOnEndResult() *OnEndResult {
 	return &OnEndResult{
-		Errors:   make([]api.Message, 0),
-		Warnings: make([]api.Message, 0),
+		Errors:   make([]*Message, 0),
+		Warnings: make([]*Message, 0),
 	}
 }
 
@@ -343,6 +366,17 @@ func (p *Plugin) SetPluginName(name string) {
 	p.name = name
 }
 
+// SetCacheKey allows builds using this plugin to be cached. The key must change
+// whenever the plugin could resolve or load anything differently, for example
+// a hash of the files it serves. Builds with a plugin without a key are never cached.
+func (p *Plugin) SetCacheKey(key string) {
+	p.cacheKey = key
+}
+
+func (p *Plugin) GetCacheKey() string {
+	return p.cacheKey
+}
+
 // OnResolve adds a resolve callback
 func (p *Plugin) OnResolve(options *OnResolveOptions, callback OnResolveCallback) {
 	p.onResolveRules = append(p.onResolveRules, onResolveRule{
@@ -359,14 +393,25 @@ func (p *Plugin) OnLoad(options *OnLoadOptions, callback OnLoadCallback) {
 	})
 }
 
-// OnStart sets the start callback
+// OnStart adds a start callback. The start callbacks of a plugin run in the
+// order they were added, before every build.
 func (p *Plugin) OnStart(callback OnStartCallback) {
-	p.onStartCallback = callback
+	p.onStartCallbacks = append(p.onStartCallbacks, callback)
 }
 
-// OnEnd sets the end callback
+// OnEnd adds an end callback. The end callbacks of a plugin run in the order
+// they were added, after every build.
 func (p *Plugin) OnEnd(callback OnEndCallback) {
-	p.onEndCallback = callback
+	p.onEndCallbacks = append(p.onEndCallbacks, callback)
+}
+
+// OnDispose adds a dispose callback, to release caches, file handles and
+// other resources. Dispose callbacks run once, in the order they were added,
+// when a single build ends or when BuildContext.Dispose is called, and have
+// returned before Build or Dispose returns.
+// Documentation: https://esbuild.github.io/plugins/#on-dispose
+func (p *Plugin) OnDispose(callback OnDisposeCallback) {
+	p.onDisposeCallbacks = append(p.onDisposeCallbacks, callback)
 }
 
 // Getter methods for rules
@@ -406,20 +451,59 @@ func (p *Plugin) GetOnLoadRuleCallback(index int) OnLoadCallback {
 	return nil
 }
 
+// GetOnStartCallback returns the first start callback, or nil
 func (p *Plugin) GetOnStartCallback() OnStartCallback {
-	return p.onStartCallback
+	return p.GetOnStartCallbackAt(0)
 }
 
+// GetOnEndCallback returns the first end callback, or nil
 func (p *Plugin) GetOnEndCallback() OnEndCallback {
-	return p.onEndCallback
+	return p.GetOnEndCallbackAt(0)
 }
 
 func (p *Plugin) HasOnStartCallback() bool {
-	return p.onStartCallback != nil
+	return len(p.onStartCallbacks) > 0
 }
 
 func (p *Plugin) HasOnEndCallback() bool {
-	return p.onEndCallback != nil
+	return len(p.onEndCallbacks) > 0
+}
+
+func (p *Plugin) HasOnDisposeCallback() bool {
+	return len(p.onDisposeCallbacks) > 0
+}
+
+func (p *Plugin) GetOnStartCallbacksCount() int {
+	return len(p.onStartCallbacks)
+}
+
+func (p *Plugin) GetOnEndCallbacksCount() int {
+	return len(p.onEndCallbacks)
+}
+
+func (p *Plugin) GetOnDisposeCallbacksCount() int {
+	return len(p.onDisposeCallbacks)
+}
+
+func (p *Plugin) GetOnStartCallbackAt(index int) OnStartCallback {
+	if index >= 0 && index < len(p.onStartCallbacks) {
+		return p.onStartCallbacks[index]
+	}
+	return nil
+}
+
+func (p *Plugin) GetOnEndCallbackAt(index int) OnEndCallback {
+	if index >= 0 && index < len(p.onEndCallbacks) {
+		return p.onEndCallbacks[index]
+	}
+	return nil
+}
+
+func (p *Plugin) GetOnDisposeCallbackAt(index int) OnDisposeCallback {
+	if index >= 0 && index < len(p.onDisposeCallbacks) {
+		return p.onDisposeCallbacks[index]
+	}
+	return nil
 }
 
 // Helper methods for creating plugin results with common patterns
@@ -660,6 +744,10 @@ func (o *OnResolveResult) SetResolveSuffix(suffix string) {
 	o.Suffix = suffix
 }
 
+func (o *OnResolveResult) SetResolvePluginData(data *PluginData) {
+	o.PluginData = data
+}
+
 func (o *OnResolveResult) AddResolveWatchFile(file string) {
 	o.WatchFiles = append(o.WatchFiles, file)
 }
@@ -668,11 +756,11 @@ func (o *OnResolveResult) AddResolveWatchDir(dir string) {
 	o.WatchDirs = append(o.WatchDirs, dir)
 }
 
-func (o *OnResolveResult) AddResolveError(message api.Message) {
+func (o *OnResolveResult) AddResolveError(message *Message) {
 	o.Errors = append(o.Errors, message)
 }
 
-func (o *OnResolveResult) AddResolveWarning(message api.Message) {
+func (o *OnResolveResult) AddResolveWarning(message *Message) {
 	o.Warnings = append(o.Warnings, message)
 }
 
@@ -694,6 +782,10 @@ func (o *OnLoadResult) SetLoadLoader(loader api.Loader) {
 	o.Loader = loader
 }
 
+func (o *OnLoadResult) SetLoadPluginData(data *PluginData) {
+	o.PluginData = data
+}
+
 func (o *OnLoadResult) AddLoadWatchFile(file string) {
 	o.WatchFiles = append(o.WatchFiles, file)
 }
@@ -702,11 +794,11 @@ func (o *OnLoadResult) AddLoadWatchDir(dir string) {
 	o.WatchDirs = append(o.WatchDirs, dir)
 }
 
-func (o *OnLoadResult) AddLoadError(message api.Message) {
+func (o *OnLoadResult) AddLoadError(message *Message) {
 	o.Errors = append(o.Errors, message)
 }
 
-func (o *OnLoadResult) AddLoadWarning(message api.Message) {
+func (o *OnLoadResult) AddLoadWarning(message *Message) {
 	o.Warnings = append(o.Warnings, message)
 }
 
@@ -718,6 +810,7 @@ func (o *OnResolveResult) GetResolveExternal() bool           { return o.Externa
 func (o *OnResolveResult) GetResolveSideEffects() SideEffects { return o.SideEffects }
 func (o *OnResolveResult) GetResolveNamespace() string        { return o.Namespace }
 func (o *OnResolveResult) GetResolveSuffix() string           { return o.Suffix }
+func (o *OnResolveResult) GetResolvePluginData() *PluginData  { return o.PluginData }
 func (o *OnResolveResult) GetResolveWatchFilesCount() int     { return len(o.WatchFiles) }
 func (o *OnResolveResult) GetResolveWatchDirsCount() int      { return len(o.WatchDirs) }
 func (o *OnResolveResult) GetResolveErrorsCount() int         { return len(o.Errors) }
@@ -737,18 +830,12 @@ func (o *OnResolveResult) GetResolveWatchDir(index int) string {
 	return ""
 }
 
-func (o *OnResolveResult) GetResolveError(index int) api.Message {
-	if index >= 0 && index < len(o.Errors) {
-		return o.Errors[index]
-	}
-	return api.Message{}
+func (o *OnResolveResult) GetResolveError(index int) *Message {
+	return getMessage(o.Errors, index)
 }
 
-func (o *OnResolveResult) GetResolveWarning(index int) api.Message {
-	if index >= 0 && index < len(o.Warnings) {
-		return o.Warnings[index]
-	}
-	return api.Message{}
+func (o *OnResolveResult) GetResolveWarning(index int) *Message {
+	return getMessage(o.Warnings, index)
 }
 
 func (o *OnLoadResult) GetLoadPluginName() string { return o.PluginName }
@@ -758,12 +845,13 @@ func (o *OnLoadResult) GetLoadContents() string {
 	}
 	return ""
 }
-func (o *OnLoadResult) GetLoadResolveDir() string   { return o.ResolveDir }
-func (o *OnLoadResult) GetLoadLoader() api.Loader   { return o.Loader }
-func (o *OnLoadResult) GetLoadWatchFilesCount() int { return len(o.WatchFiles) }
-func (o *OnLoadResult) GetLoadWatchDirsCount() int  { return len(o.WatchDirs) }
-func (o *OnLoadResult) GetLoadErrorsCount() int     { return len(o.Errors) }
-func (o *OnLoadResult) GetLoadWarningsCount() int   { return len(o.Warnings) }
+func (o *OnLoadResult) GetLoadResolveDir() string      { return o.ResolveDir }
+func (o *OnLoadResult) GetLoadLoader() api.Loader      { return o.Loader }
+func (o *OnLoadResult) GetLoadPluginData() *PluginData { return o.PluginData }
+func (o *OnLoadResult) GetLoadWatchFilesCount() int    { return len(o.WatchFiles) }
+func (o *OnLoadResult) GetLoadWatchDirsCount() int     { return len(o.WatchDirs) }
+func (o *OnLoadResult) GetLoadErrorsCount() int        { return len(o.Errors) }
+func (o *OnLoadResult) GetLoadWarningsCount() int      { return len(o.Warnings) }
 
 func (o *OnLoadResult) GetLoadWatchFile(index int) string {
 	if index >= 0 && index < len(o.WatchFiles) {
@@ -779,18 +867,126 @@ func (o *OnLoadResult) GetLoadWatchDir(index int) string {
 	return ""
 }
 
-func (o *OnLoadResult) GetLoadError(index int) api.Message {
-	if index >= 0 && index < len(o.Errors) {
-		return o.Errors[index]
+func (o *OnLoadResult) GetLoadError(index int) *Message {
+	return getMessage(o.Errors, index)
+}
+
+func (o *OnLoadResult) GetLoadWarning(index int) *Message {
+	return getMessage(o.Warnings, index)
+}
+
+// GetKind returns the kind of import being resolved, one of the ResolveKind
+// int constants
+func (a *OnResolveArgs) GetKind() int { return int(a.Kind) }
+
+// Import attribute methods for OnResolveArgs and OnLoadArgs.
+// Documentation: https://esbuild.github.io/plugins/#on-resolve-arguments
+
+func (a *OnResolveArgs) GetImportAttributesCount() int { return len(a.With) }
+func (a *OnLoadArgs) GetImportAttributesCount() int    { return len(a.With) }
+
+// GetImportAttributeKey returns the name of an import attribute, with names sorted
+func (a *OnResolveArgs) GetImportAttributeKey(index int) string {
+	return importAttributeKey(a.With, index)
+}
+
+// GetImportAttributeKey returns the name of an import attribute, with names sorted
+func (a *OnLoadArgs) GetImportAttributeKey(index int) string {
+	return importAttributeKey(a.With, index)
+}
+
+// GetImportAttribute returns the value of an import attribute, or "" if it is not set
+func (a *OnResolveArgs) GetImportAttribute(key string) string { return a.With[key] }
+func (a *OnLoadArgs) GetImportAttribute(key string) string    { return a.With[key] }
+
+func (a *OnResolveArgs) HasImportAttribute(key string) bool {
+	_, ok := a.With[key]
+	return ok
+}
+
+func (a *OnLoadArgs) HasImportAttribute(key string) bool {
+	_, ok := a.With[key]
+	return ok
+}
+
+func importAttributeKey(with map[string]string, index int) string {
+	keys := make([]string, 0, len(with))
+	for key := range with {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if index >= 0 && index < len(keys) {
+		return keys[index]
+	}
+	return ""
+}
+
+// loaderForImportAttributes picks a loader from the "type" import attribute
+func loaderForImportAttributes(with map[string]string) (api.Loader, bool) {
+	switch with["type"] {
+	case "json":
+		return api.LoaderJSON, true
+	case "text":
+		return api.LoaderText, true
+	case "bytes":
+		return api.LoaderBinary, true
+	case "css":
+		return api.LoaderCSS, true
 	}
-	return api.Message{}
+	return api.LoaderNone, false
 }
 
-func (o *OnLoadResult) GetLoadWarning(index int) api.Message {
-	if index >= 0 && index < len(o.Warnings) {
-		return o.Warnings[index]
+// SetLoadLoaderFromImportAttributes sets the loader from the "type" import
+// attribute of args: "json", "text", "bytes" or "css". It returns false and
+// keeps the current loader when there is no such attribute.
+func (o *OnLoadResult) SetLoadLoaderFromImportAttributes(args *OnLoadArgs) bool {
+	loader, ok := loaderForImportAttributes(args.With)
+	if ok {
+		o.Loader = loader
 	}
-	return api.Message{}
+	return ok
+}
+
+// Configuration and getter methods for OnStartResult
+
+func (o *OnStartResult) AddStartError(message *Message) {
+	o.Errors = append(o.Errors, message)
+}
+
+func (o *OnStartResult) AddStartWarning(message *Message) {
+	o.Warnings = append(o.Warnings, message)
+}
+
+func (o *OnStartResult) GetStartErrorsCount() int   { return len(o.Errors) }
+func (o *OnStartResult) GetStartWarningsCount() int { return len(o.Warnings) }
+
+func (o *OnStartResult) GetStartError(index int) *Message {
+	return getMessage(o.Errors, index)
+}
+
+func (o *OnStartResult) GetStartWarning(index int) *Message {
+	return getMessage(o.Warnings, index)
+}
+
+// Configuration and getter methods for OnEndResult
+
+func (o *OnEndResult) AddEndError(message *Message) {
+	o.Errors = append(o.Errors, message)
+}
+
+func (o *OnEndResult) AddEndWarning(message *Message) {
+	o.Warnings = append(o.Warnings, message)
+}
+
+func (o *OnEndResult) GetEndErrorsCount() int   { return len(o.Errors) }
+func (o *OnEndResult) GetEndWarningsCount() int { return len(o.Warnings) }
+
+func (o *OnEndResult) GetEndError(index int) *Message {
+	return getMessage(o.Errors, index)
+}
+
+func (o *OnEndResult) GetEndWarning(index int) *Message {
+	return getMessage(o.Warnings, index)
 }
 
 // Swift Bridge Callbacks
@@ -860,6 +1056,28 @@ func (c *SwiftOnEndCallback) Call(result *BuildResult) *OnEndResult {
 	return NewOnEndResult()
 }
 
+// SwiftOnDisposeCallback is a concrete implementation that can be used from Swift
+type SwiftOnDisposeCallback struct {
+	handler func()
+}
+
+// NewSwiftOnDisposeCallback creates a callback that executes a handler
+func NewSwiftOnDisposeCallback() *SwiftOnDisposeCallback {
+	return &SwiftOnDisposeCallback{}
+}
+
+// SetHandler sets the handler function
+func (c *SwiftOnDisposeCallback) SetHandler(handler func()) {
+	c.handler = handler
+}
+
+// Call implements the OnDisposeCallback interface
+func (c *SwiftOnDisposeCallback) Call() {
+	if c.handler != nil {
+		c.handler()
+	}
+}
+
 // SwiftOnResolveCallback provides resolve handling for Swift
 type SwiftOnResolveCallback struct {
 	handler func(*OnResolveArgs) *OnResolveResult
@@ -0,0 +1,128 @@
+package esbuildmobile
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestImportAttributes(t *testing.T) {
+	var resolvedTypes []string
+	resolveCallback := NewSwiftOnResolveCallback()
+	resolveCallback.SetHandler(func(args *OnResolveArgs) *OnResolveResult {
+		if args.GetImportAttributesCount() > 0 {
+			key := args.GetImportAttributeKey(0)
+			resolvedTypes = append(resolvedTypes, key+"="+args.GetImportAttribute(key))
+		}
+		return CreateNamespaceResolveResult(args.Path, NamespaceVirtual)
+	})
+
+	loadCallback := NewSwiftOnLoadCallback()
+	loadCallback.SetHandler(func(args *OnLoadArgs) *OnLoadResult {
+		if !args.HasImportAttribute("type") {
+			return CreateJSLoadResult(`export default "plain"`)
+		}
+		result := NewOnLoadResult()
+		result.SetLoadContents(`{"mode": "json"}`)
+		if !result.SetLoadLoaderFromImportAttributes(args) {
+			result.AddLoadError(NewMessage("unexpected import type " + args.GetImportAttribute("type")))
+		}
+		return result
+	})
+
+	plugin := NewPlugin("import-attributes")
+	plugin.OnResolve(CreateFilterForPath("^config:"), resolveCallback)
+	loadOptions := CreateFilterForNamespace(NamespaceVirtual)
+	loadOptions.SetLoadFilter(FilterAllFiles)
+	plugin.OnLoad(loadOptions, loadCallback)
+
+	options := NewBuildOptions()
+	options.ConfigureBundle(true)
+	options.AddPlugin(plugin)
+
+	code, err := Build(`import cfg from "config:app" with { type: "json" }; import plain from "config:app"; console.log(cfg.mode, plain)`, options)
+	if err != nil {
+		t.Fatal("build failed: ", err)
+	}
+	if !strings.Contains(code, `mode: "json"`) || !strings.Contains(code, `"plain"`) {
+		t.Fatalf("expected both imports to be loaded differently:\n%s", code)
+	}
+	if len(resolvedTypes) != 1 || resolvedTypes[0] != "type=json" {
+		t.Fatalf("expected the import attributes in the resolve args, got %v", resolvedTypes)
+	}
+
+	// Built-in plugins follow the type attribute too
+	fs := NewVirtualFS()
+	fs.AddFile("/config.js", []byte(`{"mode": "virtual"}`))
+	options = NewBuildOptions()
+	options.ConfigureBundle(true)
+	options.ConfigureVirtualFS(fs)
+	code, err = Build(`import cfg from "./config.js" with { type: "json" }; console.log(cfg.mode)`, options)
+	if err != nil {
+		t.Fatal("build failed: ", err)
+	}
+	if !strings.Contains(code, `mode: "virtual"`) {
+		t.Fatalf("expected the file to be loaded as JSON:\n%s", code)
+	}
+}
+
+func TestPluginLifecycle(t *testing.T) {
+	var events []string
+	plugin := NewPlugin("lifecycle")
+	for _, name := range []string{"first", "second"} {
+		start := NewSwiftOnStartCallback()
+		start.SetHandler(func() *OnStartResult {
+			events = append(events, "start "+name)
+			return NewOnStartResult()
+		})
+		plugin.OnStart(start)
+
+		end := NewSwiftOnEndCallback()
+		end.SetHandler(func(result *BuildResult) *OnEndResult {
+			events = append(events, "end "+name)
+			return NewOnEndResult()
+		})
+		plugin.OnEnd(end)
+
+		dispose := NewSwiftOnDisposeCallback()
+		dispose.SetHandler(func() { events = append(events, "dispose "+name) })
+		plugin.OnDispose(dispose)
+	}
+	if plugin.GetOnStartCallbacksCount() != 2 || plugin.GetOnEndCallbacksCount() != 2 || plugin.GetOnDisposeCallbacksCount() != 2 {
+		t.Fatal("expected every callback to be registered")
+	}
+
+	// Swift callbacks may return nil
+	end := NewSwiftOnEndCallback()
+	end.SetHandler(func(result *BuildResult) *OnEndResult { return nil })
+	plugin.OnEnd(end)
+
+	options := NewBuildOptions()
+	options.AddPlugin(plugin)
+
+	if _, err := Build(`console.log("lifecycle")`, options); err != nil {
+		t.Fatal("build failed: ", err)
+	}
+	expected := "start first,start second,end first,end second,dispose first,dispose second"
+	if strings.Join(events, ",") != expected {
+		t.Fatalf("expected %s, got %v", expected, events)
+	}
+
+	events = nil
+	ctx, err := NewBuildContext(`console.log("lifecycle")`, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := ctx.Rebuild(); err != nil {
+			t.Fatal("rebuild failed: ", err)
+		}
+	}
+	if strings.Contains(strings.Join(events, ","), "dispose") {
+		t.Fatalf("expected no dispose callback before Dispose, got %v", events)
+	}
+	ctx.Dispose()
+	ctx.Dispose()
+	if len(events) != 10 || events[8] != "dispose first" || events[9] != "dispose second" {
+		t.Fatalf("expected the dispose callbacks to run once when the context is disposed, got %v", events)
+	}
+}
@@ -0,0 +1,111 @@
+package esbuildmobile
+
+import "github.com/evanw/esbuild/pkg/api"
+
+// Resolver lets resolve and load callbacks resolve a path the same way
+// esbuild resolves an import, running the resolve callbacks of every plugin
+// first. It is passed in OnResolveArgs and OnLoadArgs.
+// Documentation: https://esbuild.github.io/plugins/#resolve
+//
+// A resolve callback that calls Resolve is called again for the nested
+// resolve, so it should mark that call, for example with PluginData or a
+// namespace, and return an empty result for it.
+type Resolver struct {
+	build      api.PluginBuild
+	registry   *pluginDataRegistry
+	pluginName string
+}
+
+// Resolve resolves path, which is usually relative to options.ResolveDir.
+// `ResolveOptions` is optional and defaults to an import statement.
+func (r *Resolver) Resolve(path string, options *ResolveOptions) *ResolveResult {
+	if options == nil {
+		options = NewResolveOptions()
+	}
+	pluginName := options.PluginName
+	if pluginName == "" {
+		pluginName = r.pluginName
+	}
+
+	result := r.build.Resolve(path, api.ResolveOptions{
+		PluginName: pluginName,
+		Importer:   options.Importer,
+		Namespace:  options.Namespace,
+		ResolveDir: options.ResolveDir,
+		Kind:       options.Kind.ToAPI(),
+		PluginData: r.registry.store(options.PluginData),
+		With:       options.With,
+	})
+	return &ResolveResult{
+		Errors:      messagesFromAPI(result.Errors),
+		Warnings:    messagesFromAPI(result.Warnings),
+		Path:        result.Path,
+		External:    result.External,
+		SideEffects: result.SideEffects,
+		Namespace:   result.Namespace,
+		Suffix:      result.Suffix,
+		PluginData:  r.registry.lookup(result.PluginData),
+	}
+}
+
+// NewResolveOptions creates options for resolving an import statement
+func NewResolveOptions() *ResolveOptions {
+	return &ResolveOptions{
+		Kind: ResolveJSImportStatement,
+		With: make(map[string]string),
+	}
+}
+
+// Configuration methods for ResolveOptions
+
+func (o *ResolveOptions) SetPluginName(name string)      { o.PluginName = name }
+func (o *ResolveOptions) SetImporter(importer string)    { o.Importer = importer }
+func (o *ResolveOptions) SetNamespace(namespace string)  { o.Namespace = namespace }
+func (o *ResolveOptions) SetResolveDir(dir string)       { o.ResolveDir = dir }
+func (o *ResolveOptions) SetKind(kind int)               { o.Kind = ResolveKind(kind) }
+func (o *ResolveOptions) SetPluginData(data *PluginData) { o.PluginData = data }
+
+// SetImportAttribute sets an import attribute, such as "type" to "json"
+func (o *ResolveOptions) SetImportAttribute(key string, value string) {
+	if o.With == nil {
+		o.With = make(map[string]string)
+	}
+	o.With[key] = value
+}
+
+// Getter methods for ResolveResult
+
+func (r *ResolveResult) GetPath() string            { return r.Path }
+func (r *ResolveResult) IsExternal() bool           { return r.External }
+func (r *ResolveResult) HasSideEffects() bool       { return r.SideEffects }
+func (r *ResolveResult) GetNamespace() string       { return r.Namespace }
+func (r *ResolveResult) GetSuffix() string          { return r.Suffix }
+func (r *ResolveResult) GetPluginData() *PluginData { return r.PluginData }
+func (r *ResolveResult) HasErrors() bool            { return len(r.Errors) > 0 }
+func (r *ResolveResult) GetErrorsCount() int        { return len(r.Errors) }
+func (r *ResolveResult) GetWarningsCount() int      { return len(r.Warnings) }
+
+func (r *ResolveResult) GetError(index int) *Message {
+	return getMessage(r.Errors, index)
+}
+
+func (r *ResolveResult) GetWarning(index int) *Message {
+	return getMessage(r.Warnings, index)
+}
+
+// ToOnResolveResult copies the result into a resolve callback result, so a
+// callback can return it as is or after adjusting it
+func (r *ResolveResult) ToOnResolveResult() *OnResolveResult {
+	result := NewOnResolveResult()
+	result.Errors = append(result.Errors, r.Errors...)
+	result.Warnings = append(result.Warnings, r.Warnings...)
+	result.Path = r.Path
+	result.External = r.External
+	if !r.SideEffects {
+		result.SideEffects = SideEffectsFalse
+	}
+	result.Namespace = r.Namespace
+	result.Suffix = r.Suffix
+	result.PluginData = r.PluginData
+	return result
+}
@@ -0,0 +1,84 @@
+package esbuildmobile
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolver(t *testing.T) {
+	fs := NewVirtualFS()
+	fs.AddFile("/lib/value.js", []byte(`export default "resolved by esbuild"`))
+
+	var nestedKinds []int
+	resolveCallback := NewSwiftOnResolveCallback()
+	resolveCallback.SetHandler(func(args *OnResolveArgs) *OnResolveResult {
+		if args.PluginData != nil && args.PluginData.GetString() == "nested" {
+			return NewOnResolveResult()
+		}
+		options := NewResolveOptions()
+		if args.GetKind() == ResolveKindJSImportStatement {
+			options.SetKind(GetResolveJSRequireCall())
+		}
+		options.SetResolveDir("/")
+		options.SetImporter(args.Importer)
+		options.SetPluginData(NewPluginDataString("nested"))
+		resolved := args.Resolver.Resolve("./lib/"+strings.TrimPrefix(args.Path, "@lib/"), options)
+		if resolved.HasErrors() {
+			return resolved.ToOnResolveResult()
+		}
+		result := resolved.ToOnResolveResult()
+		result.SetResolveSuffix("?adjusted")
+		return result
+	})
+
+	kindCallback := NewSwiftOnResolveCallback()
+	kindCallback.SetHandler(func(args *OnResolveArgs) *OnResolveResult {
+		nestedKinds = append(nestedKinds, args.GetKind())
+		return NewOnResolveResult()
+	})
+
+	var loadedPath string
+	loadCallback := NewSwiftOnLoadCallback()
+	loadCallback.SetHandler(func(args *OnLoadArgs) *OnLoadResult {
+		resolved := args.Resolver.Resolve("@lib/value", nil)
+		loadedPath = resolved.GetPath() + resolved.GetSuffix()
+		return CreateJSLoadResult(`export { default } from "@lib/value"`)
+	})
+
+	plugin := NewPlugin("resolver")
+	plugin.OnResolve(CreateFilterForPath("^@lib/"), resolveCallback)
+	plugin.OnResolve(CreateFilterForPath("^\\./lib/"), kindCallback)
+	plugin.OnResolve(CreateFilterForPath("^entry:"), &SimpleResolveCallback{Path: "entry", Namespace: NamespaceGenerated})
+	loadOptions := CreateFilterForNamespace(NamespaceGenerated)
+	loadOptions.SetLoadFilter(FilterAllFiles)
+	plugin.OnLoad(loadOptions, loadCallback)
+
+	options := NewBuildOptions()
+	options.ConfigureBundle(true)
+	options.ConfigureVirtualFS(fs)
+	options.AddPlugin(plugin)
+
+	code, err := Build(`import value from "entry:"; console.log(value)`, options)
+	if err != nil {
+		t.Fatal("build failed: ", err)
+	}
+	if !strings.Contains(code, `"resolved by esbuild"`) || !strings.Contains(code, "value.js?adjusted") {
+		t.Fatalf("expected the adjusted resolve result in output:\n%s", code)
+	}
+	if loadedPath != "/lib/value.js?adjusted" {
+		t.Fatalf("expected the load callback to resolve through every plugin, got %q", loadedPath)
+	}
+	for _, kind := range nestedKinds {
+		if kind != ResolveKindJSRequireCall {
+			t.Fatalf("expected the kind set on the resolve options, got %v", nestedKinds)
+		}
+	}
+	if len(nestedKinds) == 0 {
+		t.Fatal("expected nested resolves")
+	}
+
+	_, err = Build(`import "@lib/missing"`, options)
+	if err == nil || !strings.Contains(err.Error(), "Could not resolve") {
+		t.Fatalf("expected the nested resolve error, got %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package esbuildmobile
+
+import (
+	"encoding/json"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// TransformResult represents the full result of a transform
+type TransformResult struct {
+	Errors   []*Message
+	Warnings []*Message
+
+	Code          string
+	Map           string
+	LegalComments string
+	MangleCache   map[string]interface{}
+}
+
+// transformResultFromAPI converts esbuild's api.TransformResult to our TransformResult
+func transformResultFromAPI(result api.TransformResult) *TransformResult {
+	return &TransformResult{
+		Errors:        messagesFromAPI(result.Errors),
+		Warnings:      messagesFromAPI(result.Warnings),
+		Code:          string(result.Code),
+		Map:           string(result.Map),
+		LegalComments: string(result.LegalComments),
+		MangleCache:   result.MangleCache,
+	}
+}
+
+// BuildResult represents the full result of a build
+type BuildResult struct {
+	Errors   []*Message
+	Warnings []*Message
+
+	OutputFiles []*OutputFile
+	Metafile    string
+	MangleCache map[string]interface{}
+}
+
+// OutputFile represents a single file emitted by a build
+type OutputFile struct {
+	Path     string
+	Contents []byte
+	Hash     string
+}
+
+// buildResultFromAPI converts esbuild's api.BuildResult to our BuildResult
+func buildResultFromAPI(result *api.BuildResult) *BuildResult {
+	outputFiles := make([]*OutputFile, len(result.OutputFiles))
+	for i, file := range result.OutputFiles {
+		outputFiles[i] = &OutputFile{
+			Path:     file.Path,
+			Contents: file.Contents,
+			Hash:     file.Hash,
+		}
+	}
+	return &BuildResult{
+		Errors:      messagesFromAPI(result.Errors),
+		Warnings:    messagesFromAPI(result.Warnings),
+		OutputFiles: outputFiles,
+		Metafile:    result.Metafile,
+		MangleCache: result.MangleCache,
+	}
+}
+
+// Getter methods for TransformResult
+
+func (r *TransformResult) GetCode() string          { return r.Code }
+func (r *TransformResult) GetMap() string           { return r.Map }
+func (r *TransformResult) GetLegalComments() string { return r.LegalComments }
+func (r *TransformResult) HasMap() bool             { return r.Map != "" }
+func (r *TransformResult) HasErrors() bool          { return len(r.Errors) > 0 }
+func (r *TransformResult) GetErrorsCount() int      { return len(r.Errors) }
+func (r *TransformResult) GetWarningsCount() int    { return len(r.Warnings) }
+func (r *TransformResult) GetMangleCacheCount() int { return len(r.MangleCache) }
+
+func (r *TransformResult) GetError(index int) *Message {
+	return getMessage(r.Errors, index)
+}
+
+func (r *TransformResult) GetWarning(index int) *Message {
+	return getMessage(r.Warnings, index)
+}
+
+// GetBuildError returns every error as a BuildError, or nil if the transform succeeded
+func (r *TransformResult) GetBuildError() *BuildError {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+	return &BuildError{Messages: r.Errors}
+}
+
+// GetMangleCacheJSON returns the updated mangle cache encoded as JSON
+// so it can be stored by the host and passed back on the next transform
+func (r *TransformResult) GetMangleCacheJSON() string {
+	return mangleCacheToJSON(r.MangleCache)
+}
+
+// mangleCacheToJSON encodes a mangle cache, returning "" when it is empty
+func mangleCacheToJSON(cache map[string]interface{}) string {
+	if len(cache) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// Getter methods for BuildResult
+
+func (r *BuildResult) GetMetafile() string      { return r.Metafile }
+func (r *BuildResult) HasMetafile() bool        { return r.Metafile != "" }
+func (r *BuildResult) HasErrors() bool          { return len(r.Errors) > 0 }
+func (r *BuildResult) GetErrorsCount() int      { return len(r.Errors) }
+func (r *BuildResult) GetWarningsCount() int    { return len(r.Warnings) }
+func (r *BuildResult) GetOutputFilesCount() int { return len(r.OutputFiles) }
+func (r *BuildResult) GetMangleCacheCount() int { return len(r.MangleCache) }
+
+func (r *BuildResult) GetError(index int) *Message {
+	return getMessage(r.Errors, index)
+}
+
+func (r *BuildResult) GetWarning(index int) *Message {
+	return getMessage(r.Warnings, index)
+}
+
+func (r *BuildResult) GetOutputFile(index int) *OutputFile {
+	if index >= 0 && index < len(r.OutputFiles) {
+		return r.OutputFiles[index]
+	}
+	return nil
+}
+
+// GetOutputFileByPath returns the output file with the given absolute path, or nil
+func (r *BuildResult) GetOutputFileByPath(path string) *OutputFile {
+	for _, file := range r.OutputFiles {
+		if file.Path == path {
+			return file
+		}
+	}
+	return nil
+}
+
+// GetBuildError returns every error as a BuildError, or nil if the build succeeded
+func (r *BuildResult) GetBuildError() *BuildError {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+	return &BuildError{Messages: r.Errors}
+}
+
+// GetMangleCacheJSON returns the updated mangle cache encoded as JSON
+func (r *BuildResult) GetMangleCacheJSON() string {
+	return mangleCacheToJSON(r.MangleCache)
+}
+
+// Getter methods for OutputFile
+
+func (f *OutputFile) GetPath() string     { return f.Path }
+func (f *OutputFile) GetContents() []byte { return f.Contents }
+func (f *OutputFile) GetText() string     { return string(f.Contents) }
+func (f *OutputFile) GetHash() string     { return f.Hash }
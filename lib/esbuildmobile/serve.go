@@ -0,0 +1,156 @@
+package esbuildmobile
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// LiveReloadScript reloads the page whenever the dev server reports a change.
+// Add it to the served page, or inject it with a banner, to enable live reload.
+// Documentation: https://esbuild.github.io/api/#live-reload
+const LiveReloadScript = `new EventSource("/esbuild").addEventListener("change", () => location.reload());`
+
+// ServeOptions contains options for the dev server
+// Documentation: https://esbuild.github.io/api/#serve
+type ServeOptions struct {
+	Host     string
+	Port     int
+	Servedir string
+	Keyfile  string
+	Certfile string
+	Fallback string
+
+	// LiveReload starts watch mode together with the server so that the
+	// /esbuild event stream reports a change after every rebuild
+	LiveReload bool
+
+	CORSOrigins []string
+}
+
+// ServeResult describes the address the dev server is listening on
+type ServeResult struct {
+	Host   string
+	Port   int
+	Hosts  []string
+	Secure bool
+}
+
+// NewServeOptions creates ServeOptions with default values
+func NewServeOptions() *ServeOptions {
+	return &ServeOptions{}
+}
+
+func (o *ServeOptions) ToAPI() api.ServeOptions {
+	return api.ServeOptions{
+		Host:     o.Host,
+		Port:     o.Port,
+		Servedir: o.Servedir,
+		Keyfile:  o.Keyfile,
+		Certfile: o.Certfile,
+		Fallback: o.Fallback,
+		CORS:     api.CORSOptions{Origin: o.CORSOrigins},
+	}
+}
+
+// Configuration methods for ServeOptions
+
+func (o *ServeOptions) ConfigureHost(host string)         { o.Host = host }
+func (o *ServeOptions) ConfigurePort(port int)            { o.Port = port }
+func (o *ServeOptions) ConfigureServedir(dir string)      { o.Servedir = dir }
+func (o *ServeOptions) ConfigureFallback(fallback string) { o.Fallback = fallback }
+func (o *ServeOptions) ConfigureLiveReload(enabled bool)  { o.LiveReload = enabled }
+
+// ConfigureTLS serves over HTTPS using the given key and certificate files
+func (o *ServeOptions) ConfigureTLS(keyfile string, certfile string) {
+	o.Keyfile = keyfile
+	o.Certfile = certfile
+}
+
+func (o *ServeOptions) AddCORSOrigin(origin string) {
+	o.CORSOrigins = append(o.CORSOrigins, origin)
+}
+
+// Serve starts esbuild's dev server for this context. Each request for an
+// output file waits for the latest build. The server stops when the context
+// is disposed. With LiveReload, watch mode is started before the server and
+// keeps running if the server fails to start.
+// `ServeOptions` is optional.
+func (c *BuildContext) Serve(options *ServeOptions) (*ServeResult, error) {
+	if options == nil {
+		options = NewServeOptions()
+	}
+
+	c.mutex.Lock()
+	if c.disposed {
+		c.mutex.Unlock()
+		return nil, ErrContextDisposed
+	}
+	ctx := c.ctx
+	callback := c.onRebuild
+	watching := c.watching
+	c.mutex.Unlock()
+
+	// Watch mode starts first, so a failure leaves no server running that
+	// the caller cannot stop
+	if options.LiveReload && !watching {
+		if err := c.Watch(callback); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := ctx.Serve(options.ToAPI())
+	if err != nil {
+		return nil, err
+	}
+
+	serveResult := &ServeResult{
+		Host:   options.Host,
+		Port:   int(result.Port),
+		Hosts:  result.Hosts,
+		Secure: options.Keyfile != "" && options.Certfile != "",
+	}
+	if len(result.Hosts) > 0 && (serveResult.Host == "" || serveResult.Host == "0.0.0.0") {
+		serveResult.Host = result.Hosts[0]
+	}
+	return serveResult, nil
+}
+
+// Getter methods for ServeResult
+
+func (r *ServeResult) GetHost() string    { return r.Host }
+func (r *ServeResult) GetPort() int       { return r.Port }
+func (r *ServeResult) IsSecure() bool     { return r.Secure }
+func (r *ServeResult) GetHostsCount() int { return len(r.Hosts) }
+
+func (r *ServeResult) GetHostAt(index int) string {
+	if index >= 0 && index < len(r.Hosts) {
+		return r.Hosts[index]
+	}
+	return ""
+}
+
+// GetAddress returns the bound address as "host:port"
+func (r *ServeResult) GetAddress() string {
+	return net.JoinHostPort(r.Host, strconv.Itoa(r.Port))
+}
+
+// GetURL returns the base URL of the server, such as "http://127.0.0.1:8000"
+func (r *ServeResult) GetURL() string {
+	scheme := "http"
+	if r.Secure {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s", scheme, r.GetAddress())
+}
+
+// GetEventStreamURL returns the URL of the server-sent events stream that
+// emits a "change" event after every rebuild
+func (r *ServeResult) GetEventStreamURL() string {
+	return r.GetURL() + "/esbuild"
+}
+
+// GetLiveReloadScript returns LiveReloadScript (gomobile friendly)
+func GetLiveReloadScript() string { return LiveReloadScript }
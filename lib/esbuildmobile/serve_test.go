@@ -0,0 +1,88 @@
+package esbuildmobile
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeWithLiveReload(t *testing.T) {
+	if testing.Short() {
+		t.Skip("live reload relies on watch mode polling the file system")
+	}
+
+	dir := t.TempDir()
+	entry := filepath.Join(dir, "app.js")
+	if err := os.WriteFile(entry, []byte(`console.log("first")`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	options := NewBuildOptions()
+	options.ConfigureBundle(true)
+	options.ConfigureOutdir(filepath.Join(dir, "out"))
+	options.AddEntryPoint(entry)
+
+	ctx, err := NewBuildContext("", options)
+	if err != nil {
+		t.Fatal("could not create context: ", err)
+	}
+	defer ctx.Dispose()
+
+	serveOptions := NewServeOptions()
+	serveOptions.ConfigureHost("127.0.0.1")
+	serveOptions.ConfigureLiveReload(true)
+	server, err := ctx.Serve(serveOptions)
+	if err != nil {
+		t.Fatal("could not start server: ", err)
+	}
+	if server.GetPort() == 0 || !strings.HasPrefix(server.GetURL(), "http://127.0.0.1:") {
+		t.Fatalf("unexpected server address: %s", server.GetURL())
+	}
+
+	response, err := http.Get(server.GetURL() + "/app.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+	if !strings.Contains(string(body), "first") {
+		t.Fatalf("unexpected bundle:\n%s", body)
+	}
+
+	// Request the stream the same way EventSource does
+	request, _ := http.NewRequest("GET", server.GetEventStreamURL(), nil)
+	request.Header.Set("Accept", "text/event-stream")
+	events, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.Body.Close()
+	if contentType := events.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", contentType)
+	}
+
+	changed := make(chan struct{})
+	go func() {
+		scanner := bufio.NewScanner(events.Body)
+		for scanner.Scan() {
+			if scanner.Text() == "event: change" {
+				close(changed)
+				return
+			}
+		}
+	}()
+
+	if err := os.WriteFile(entry, []byte(`console.log("second")`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for a change event")
+	}
+}
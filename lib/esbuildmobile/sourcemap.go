@@ -0,0 +1,394 @@
+package esbuildmobile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SourceMap is a decoded version 3 source map. Both regular maps and
+// index maps with sections are supported; sections are flattened on parse.
+//
+// Lines are 1-based and columns are 0-based, matching Location.
+// Documentation: https://sourcemaps.info/spec.html
+type SourceMap struct {
+	File           string
+	SourceRoot     string
+	Sources        []string
+	SourcesContent []string
+	Names          []string
+
+	// hasContent tracks which entries of SourcesContent were present (not null)
+	hasContent []bool
+	// mappings are sorted by generated line, then generated column
+	mappings []sourceMapping
+}
+
+// OriginalPosition is the original location of a generated position
+type OriginalPosition struct {
+	Source string
+	Line   int // 1-based
+	Column int // 0-based
+	Name   string
+}
+
+// GeneratedPosition is a location in the generated code
+type GeneratedPosition struct {
+	Line   int // 1-based
+	Column int // 0-based
+}
+
+// sourceMapping is a single decoded segment. All values are 0-based and
+// source/name are -1 when the segment does not have them.
+type sourceMapping struct {
+	generatedLine   int
+	generatedColumn int
+	source          int
+	originalLine    int
+	originalColumn  int
+	name            int
+}
+
+// JSON shape of a source map
+type sourceMapJSON struct {
+	Version        int                    `json:"version"`
+	File           string                 `json:"file"`
+	SourceRoot     string                 `json:"sourceRoot"`
+	Sources        []*string              `json:"sources"`
+	SourcesContent []*string              `json:"sourcesContent"`
+	Names          []string               `json:"names"`
+	Mappings       string                 `json:"mappings"`
+	Sections       []sourceMapSectionJSON `json:"sections"`
+}
+
+type sourceMapSectionJSON struct {
+	Offset struct {
+		Line   int `json:"line"`
+		Column int `json:"column"`
+	} `json:"offset"`
+	Map *sourceMapJSON `json:"map"`
+}
+
+// ParseSourceMap decodes a source map from its JSON text
+func ParseSourceMap(text string) (*SourceMap, error) {
+	var raw sourceMapJSON
+	if err := json.Unmarshal([]byte(text), &raw); err != nil {
+		return nil, err
+	}
+	if raw.Version != 3 {
+		return nil, fmt.Errorf("unsupported source map version %d", raw.Version)
+	}
+
+	sourceMap := &SourceMap{
+		File:       raw.File,
+		SourceRoot: raw.SourceRoot,
+	}
+	if raw.Sections != nil {
+		for i, section := range raw.Sections {
+			if section.Map == nil {
+				return nil, fmt.Errorf("source map section %d has no map", i)
+			}
+			if err := sourceMap.addSection(section.Map, section.Offset.Line, section.Offset.Column); err != nil {
+				return nil, err
+			}
+		}
+	} else if err := sourceMap.addSection(&raw, 0, 0); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(sourceMap.mappings, func(i, j int) bool {
+		a, b := sourceMap.mappings[i], sourceMap.mappings[j]
+		if a.generatedLine != b.generatedLine {
+			return a.generatedLine < b.generatedLine
+		}
+		return a.generatedColumn < b.generatedColumn
+	})
+	return sourceMap, nil
+}
+
+// addSection appends the sources, names and mappings of a (sub) map,
+// shifting its generated positions by the given 0-based offset
+func (m *SourceMap) addSection(raw *sourceMapJSON, lineOffset int, columnOffset int) error {
+	if raw.Sections != nil {
+		return errors.New("nested source map sections are not supported")
+	}
+
+	sourceBase := len(m.Sources)
+	nameBase := len(m.Names)
+	for i, source := range raw.Sources {
+		path := ""
+		if source != nil {
+			path = joinSourceRoot(raw.SourceRoot, *source)
+		}
+		content, hasContent := "", false
+		if i < len(raw.SourcesContent) && raw.SourcesContent[i] != nil {
+			content, hasContent = *raw.SourcesContent[i], true
+		}
+		m.Sources = append(m.Sources, path)
+		m.SourcesContent = append(m.SourcesContent, content)
+		m.hasContent = append(m.hasContent, hasContent)
+	}
+	m.Names = append(m.Names, raw.Names...)
+
+	mappings, err := decodeMappings(raw.Mappings, len(raw.Sources), len(raw.Names))
+	if err != nil {
+		return err
+	}
+	for _, mapping := range mappings {
+		if mapping.generatedLine == 0 {
+			mapping.generatedColumn += columnOffset
+		}
+		mapping.generatedLine += lineOffset
+		if mapping.source >= 0 {
+			mapping.source += sourceBase
+		}
+		if mapping.name >= 0 {
+			mapping.name += nameBase
+		}
+		m.mappings = append(m.mappings, mapping)
+	}
+	return nil
+}
+
+func joinSourceRoot(root string, source string) string {
+	if root == "" || strings.Contains(source, "://") || strings.HasPrefix(source, "/") {
+		return source
+	}
+	return strings.TrimSuffix(root, "/") + "/" + source
+}
+
+// decodeMappings decodes the VLQ "mappings" field
+func decodeMappings(encoded string, sourcesCount int, namesCount int) ([]sourceMapping, error) {
+	mappings := make([]sourceMapping, 0)
+	line, source, originalLine, originalColumn, name := 0, 0, 0, 0, 0
+
+	for _, lineText := range strings.Split(encoded, ";") {
+		column := 0
+		for _, segment := range strings.Split(lineText, ",") {
+			if segment == "" {
+				continue
+			}
+			values, err := decodeVLQSegment(segment)
+			if err != nil {
+				return nil, fmt.Errorf("invalid mapping on line %d: %v", line+1, err)
+			}
+
+			column += values[0]
+			mapping := sourceMapping{
+				generatedLine:   line,
+				generatedColumn: column,
+				source:          -1,
+				name:            -1,
+			}
+
+			switch len(values) {
+			case 1:
+			case 4, 5:
+				source += values[1]
+				originalLine += values[2]
+				originalColumn += values[3]
+				if source < 0 || source >= sourcesCount {
+					return nil, fmt.Errorf("invalid source index %d on line %d", source, line+1)
+				}
+				mapping.source = source
+				mapping.originalLine = originalLine
+				mapping.originalColumn = originalColumn
+				if len(values) == 5 {
+					name += values[4]
+					if name < 0 || name >= namesCount {
+						return nil, fmt.Errorf("invalid name index %d on line %d", name, line+1)
+					}
+					mapping.name = name
+				}
+			default:
+				return nil, fmt.Errorf("invalid segment %q on line %d", segment, line+1)
+			}
+			mappings = append(mappings, mapping)
+		}
+		line++
+	}
+	return mappings, nil
+}
+
+const base64Alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// decodeVLQSegment decodes every base64 VLQ value in a segment
+func decodeVLQSegment(segment string) ([]int, error) {
+	values := make([]int, 0, 5)
+	value, shift := 0, 0
+	for i := 0; i < len(segment); i++ {
+		digit := strings.IndexByte(base64Alphabet, segment[i])
+		if digit < 0 {
+			return nil, fmt.Errorf("invalid base64 character %q", segment[i])
+		}
+		value += (digit & 31) << shift
+		if digit&32 != 0 {
+			shift += 5
+			continue
+		}
+		if value&1 != 0 {
+			values = append(values, -(value >> 1))
+		} else {
+			values = append(values, value>>1)
+		}
+		value, shift = 0, 0
+	}
+	if shift != 0 {
+		return nil, errors.New("truncated VLQ value")
+	}
+	return values, nil
+}
+
+// OriginalPositionFor returns the original position of a generated line
+// (1-based) and column (0-based), or nil if the position is not mapped
+func (m *SourceMap) OriginalPositionFor(line int, column int) *OriginalPosition {
+	generatedLine := line - 1
+
+	// Find the first mapping after the position, then step back one
+	index := sort.Search(len(m.mappings), func(i int) bool {
+		mapping := m.mappings[i]
+		if mapping.generatedLine != generatedLine {
+			return mapping.generatedLine > generatedLine
+		}
+		return mapping.generatedColumn > column
+	}) - 1
+	if index < 0 || m.mappings[index].generatedLine != generatedLine {
+		return nil
+	}
+
+	mapping := m.mappings[index]
+	if mapping.source < 0 {
+		return nil
+	}
+	position := &OriginalPosition{
+		Source: m.Sources[mapping.source],
+		Line:   mapping.originalLine + 1,
+		Column: mapping.originalColumn,
+	}
+	if mapping.name >= 0 {
+		position.Name = m.Names[mapping.name]
+	}
+	return position
+}
+
+// GeneratedPositionFor returns the generated position of an original line
+// (1-based) and column (0-based) in the given source, or nil if that line
+// has no mappings. The closest mapping at or before the column is used,
+// falling back to the first mapping on the line.
+func (m *SourceMap) GeneratedPositionFor(source string, line int, column int) *GeneratedPosition {
+	sourceIndex := m.sourceIndex(source)
+	if sourceIndex < 0 {
+		return nil
+	}
+
+	originalLine := line - 1
+	var best, first *sourceMapping
+	for i := range m.mappings {
+		mapping := &m.mappings[i]
+		if mapping.source != sourceIndex || mapping.originalLine != originalLine {
+			continue
+		}
+		if first == nil || mapping.originalColumn < first.originalColumn {
+			first = mapping
+		}
+		if mapping.originalColumn <= column && (best == nil || mapping.originalColumn > best.originalColumn) {
+			best = mapping
+		}
+	}
+	if best == nil {
+		best = first
+	}
+	if best == nil {
+		return nil
+	}
+	return &GeneratedPosition{
+		Line:   best.generatedLine + 1,
+		Column: best.generatedColumn,
+	}
+}
+
+// sourceIndex finds a source by exact path, falling back to a path suffix match
+func (m *SourceMap) sourceIndex(source string) int {
+	for i, path := range m.Sources {
+		if path == source {
+			return i
+		}
+	}
+	for i, path := range m.Sources {
+		if strings.HasSuffix(path, "/"+source) {
+			return i
+		}
+	}
+	return -1
+}
+
+// GetParsedSourceMap decodes the source map of the transform. It returns an
+// error if TransformOptions.Sourcemap did not produce an external map; check
+// with HasMap first.
+func (r *TransformResult) GetParsedSourceMap() (*SourceMap, error) {
+	if r.Map == "" {
+		return nil, errors.New("no source map was produced, set TransformOptions.Sourcemap to external")
+	}
+	return ParseSourceMap(r.Map)
+}
+
+// GetParsedSourceMap decodes the source map emitted for the given output
+// path (the "<path>.map" output file). It returns an error if there is none.
+func (r *BuildResult) GetParsedSourceMap(outputPath string) (*SourceMap, error) {
+	file := r.GetOutputFileByPath(outputPath + ".map")
+	if file == nil {
+		return nil, fmt.Errorf("no source map was produced for %s", outputPath)
+	}
+	return ParseSourceMap(string(file.Contents))
+}
+
+// Getter methods for SourceMap
+
+func (m *SourceMap) GetFile() string       { return m.File }
+func (m *SourceMap) GetSourceRoot() string { return m.SourceRoot }
+func (m *SourceMap) GetSourcesCount() int  { return len(m.Sources) }
+func (m *SourceMap) GetNamesCount() int    { return len(m.Names) }
+func (m *SourceMap) GetMappingsCount() int { return len(m.mappings) }
+
+func (m *SourceMap) GetSource(index int) string {
+	if index >= 0 && index < len(m.Sources) {
+		return m.Sources[index]
+	}
+	return ""
+}
+
+func (m *SourceMap) GetName(index int) string {
+	if index >= 0 && index < len(m.Names) {
+		return m.Names[index]
+	}
+	return ""
+}
+
+// HasSourceContent reports whether sourcesContent includes the given source
+func (m *SourceMap) HasSourceContent(source string) bool {
+	index := m.sourceIndex(source)
+	return index >= 0 && m.hasContent[index]
+}
+
+// GetSourceContent returns the original contents of the given source, if embedded
+func (m *SourceMap) GetSourceContent(source string) string {
+	if index := m.sourceIndex(source); index >= 0 {
+		return m.SourcesContent[index]
+	}
+	return ""
+}
+
+// Getter methods for OriginalPosition
+
+func (p *OriginalPosition) GetSource() string { return p.Source }
+func (p *OriginalPosition) GetLine() int      { return p.Line }
+func (p *OriginalPosition) GetColumn() int    { return p.Column }
+func (p *OriginalPosition) GetName() string   { return p.Name }
+func (p *OriginalPosition) HasName() bool     { return p.Name != "" }
+
+// Getter methods for GeneratedPosition
+
+func (p *GeneratedPosition) GetLine() int   { return p.Line }
+func (p *GeneratedPosition) GetColumn() int { return p.Column }
@@ -0,0 +1,98 @@
+package esbuildmobile
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestSourceMapLookup(t *testing.T) {
+	options := NewTransformOptions()
+	options.ConfigureSourcemap(api.SourceMapExternal)
+	options.ConfigureSourcefile("app.js")
+	options.ConfigureMinifyWhitespace(true)
+
+	input := "function greet(name) {\n  return 'hi ' + name\n}\nthrow greet('x')\n"
+	result, err := TransformJSXWithResult(input, options)
+	if err != nil {
+		t.Fatal("transform failed: ", err)
+	}
+
+	sourceMap, err := result.GetParsedSourceMap()
+	if err != nil {
+		t.Fatal("could not parse source map: ", err)
+	}
+	if sourceMap.GetSourcesCount() != 1 || sourceMap.GetSource(0) != "app.js" {
+		t.Fatalf("unexpected sources: %v", sourceMap.Sources)
+	}
+	if sourceMap.GetSourceContent("app.js") != input {
+		t.Fatal("expected sourcesContent to be embedded")
+	}
+
+	// Minified output is a single line, so "throw" maps back to line 4
+	generated := sourceMap.GeneratedPositionFor("app.js", 4, 0)
+	if generated == nil || generated.GetLine() != 1 {
+		t.Fatalf("unexpected generated position: %+v", generated)
+	}
+	original := sourceMap.OriginalPositionFor(generated.GetLine(), generated.GetColumn())
+	if original == nil || original.GetSource() != "app.js" || original.GetLine() != 4 || original.GetColumn() != 0 {
+		t.Fatalf("unexpected original position: %+v", original)
+	}
+
+	if sourceMap.OriginalPositionFor(10, 0) != nil {
+		t.Fatal("expected unmapped line to return nil")
+	}
+
+	options.ConfigureSourcemap(api.SourceMapNone)
+	if result, err = TransformJSXWithResult(input, options); err != nil {
+		t.Fatal("transform failed: ", err)
+	}
+	if _, err := result.GetParsedSourceMap(); err == nil || !strings.Contains(err.Error(), "no source map was produced") {
+		t.Fatalf("expected an error without a source map, got %v", err)
+	}
+	if _, err := (&BuildResult{}).GetParsedSourceMap("out.js"); err == nil || !strings.Contains(err.Error(), "no source map was produced for out.js") {
+		t.Fatalf("expected an error without a source map output, got %v", err)
+	}
+}
+
+func TestIndexSourceMap(t *testing.T) {
+	// Two sections: "a.js" at line 1 and "b.js" starting at line 3, column 2.
+	// "AAAAA" maps column 0 to source 0, line 0, column 0, name 0.
+	indexMap := `{
+		"version": 3,
+		"sections": [
+			{"offset": {"line": 0, "column": 0}, "map": {"version": 3, "sources": ["a.js"], "names": ["foo"], "mappings": "AAAAA"}},
+			{"offset": {"line": 2, "column": 2}, "map": {"version": 3, "sources": ["b.js"], "names": ["bar"], "mappings": "AAAAA;AACA"}}
+		]
+	}`
+
+	sourceMap, err := ParseSourceMap(indexMap)
+	if err != nil {
+		t.Fatal("could not parse index map: ", err)
+	}
+
+	first := sourceMap.OriginalPositionFor(1, 0)
+	if first == nil || first.GetSource() != "a.js" || first.GetName() != "foo" {
+		t.Fatalf("unexpected first position: %+v", first)
+	}
+
+	second := sourceMap.OriginalPositionFor(3, 2)
+	if second == nil || second.GetSource() != "b.js" || second.GetName() != "bar" || second.GetLine() != 1 {
+		t.Fatalf("unexpected second position: %+v", second)
+	}
+	if sourceMap.OriginalPositionFor(3, 1) != nil {
+		t.Fatal("expected position before the section offset to be unmapped")
+	}
+
+	// The column offset only applies to the first line of a section
+	third := sourceMap.OriginalPositionFor(4, 0)
+	if third == nil || third.GetSource() != "b.js" || third.GetLine() != 2 {
+		t.Fatalf("unexpected third position: %+v", third)
+	}
+
+	generated := sourceMap.GeneratedPositionFor("b.js", 2, 0)
+	if generated == nil || generated.GetLine() != 4 || generated.GetColumn() != 0 {
+		t.Fatalf("unexpected generated position: %+v", generated)
+	}
+}
@@ -0,0 +1,225 @@
+package esbuildmobile
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SymbolicatedStack is a JavaScript stack trace rewritten against a source map
+type SymbolicatedStack struct {
+	Message string // any lines before the first frame, such as "TypeError: ..."
+	Frames  []*StackFrame
+}
+
+// StackFrame is a single frame of a stack trace. Lines and columns are
+// 1-based, the same as in the stack traces printed by JavaScript engines.
+type StackFrame struct {
+	Raw          string
+	FunctionName string
+	File         string
+	Line         int
+	Column       int
+
+	Symbolicated         bool
+	OriginalFile         string
+	OriginalLine         int
+	OriginalColumn       int
+	OriginalFunctionName string
+}
+
+var (
+	// V8 and Hermes: "at fn (file:1:2)", "at file:1:2", "at fn (address at file:1:2)"
+	v8FrameRegexp = regexp.MustCompile(`^\s*at (?:(.+?) \()?(?:address at )?(.+?):(\d+):(\d+)\)?$`)
+	// V8 and Hermes frames without a location: "at fn (native)", "at <anonymous>"
+	v8BareFrameRegexp = regexp.MustCompile(`^\s*at (.+?)(?: \((.*)\))?$`)
+	// JavaScriptCore: "fn@file:1:2", "@file:1:2", "file:1:2"
+	jscFrameRegexp = regexp.MustCompile(`^\s*(?:(.*?)@)?(.+?):(\d+):(\d+)$`)
+	// JavaScriptCore frames without a location: "fn@[native code]", "[native code]"
+	jscBareFrameRegexp = regexp.MustCompile(`^\s*(?:(.*?)@)?(\[native code\])$`)
+)
+
+// ParseStackTrace parses a JavaScriptCore, V8 or Hermes stack trace without
+// symbolicating it. Lines before the first frame are kept as the message.
+func ParseStackTrace(stack string) *SymbolicatedStack {
+	result := &SymbolicatedStack{Frames: make([]*StackFrame, 0)}
+	messageLines := make([]string, 0)
+
+	for _, line := range strings.Split(strings.TrimRight(stack, "\n"), "\n") {
+		line = strings.TrimRight(line, "\r")
+		frame := parseStackFrame(line)
+		if frame == nil {
+			if len(result.Frames) == 0 {
+				messageLines = append(messageLines, line)
+				continue
+			}
+			frame = &StackFrame{Raw: line}
+		}
+		result.Frames = append(result.Frames, frame)
+	}
+
+	result.Message = strings.Join(messageLines, "\n")
+	return result
+}
+
+func parseStackFrame(line string) *StackFrame {
+	if strings.TrimSpace(line) == "" {
+		return nil
+	}
+	if match := v8FrameRegexp.FindStringSubmatch(line); match != nil {
+		return newStackFrame(line, match[1], match[2], match[3], match[4])
+	}
+	if match := v8BareFrameRegexp.FindStringSubmatch(line); match != nil {
+		return &StackFrame{Raw: line, FunctionName: match[1], File: match[2]}
+	}
+	if match := jscFrameRegexp.FindStringSubmatch(line); match != nil {
+		return newStackFrame(line, match[1], match[2], match[3], match[4])
+	}
+	if match := jscBareFrameRegexp.FindStringSubmatch(line); match != nil {
+		return &StackFrame{Raw: line, FunctionName: match[1], File: match[2]}
+	}
+	return nil
+}
+
+func newStackFrame(raw string, functionName string, file string, line string, column string) *StackFrame {
+	frame := &StackFrame{
+		Raw:          raw,
+		FunctionName: functionName,
+		File:         file,
+	}
+	frame.Line, _ = strconv.Atoi(line)
+	frame.Column, _ = strconv.Atoi(column)
+	return frame
+}
+
+// Symbolicate rewrites every frame of a JavaScriptCore, V8 or Hermes stack
+// trace to the original file, line and function name using a source map
+// produced by Build or TransformJSX
+func Symbolicate(stack string, sourceMap string) (*SymbolicatedStack, error) {
+	parsedMap, err := ParseSourceMap(sourceMap)
+	if err != nil {
+		return nil, err
+	}
+	return SymbolicateWithSourceMap(stack, parsedMap), nil
+}
+
+// SymbolicateWithSourceMap is like Symbolicate but reuses an already parsed source map
+func SymbolicateWithSourceMap(stack string, sourceMap *SourceMap) *SymbolicatedStack {
+	result := ParseStackTrace(stack)
+
+	for _, frame := range result.Frames {
+		if !frame.HasLocation() || !sourceMapMatchesFile(sourceMap, frame.File) {
+			continue
+		}
+		position := sourceMap.OriginalPositionFor(frame.Line, frame.Column-1)
+		if position == nil {
+			continue
+		}
+		frame.Symbolicated = true
+		frame.OriginalFile = position.Source
+		frame.OriginalLine = position.Line
+		frame.OriginalColumn = position.Column + 1
+	}
+
+	// A call site maps to the name of the function being called, so the
+	// original name of each frame comes from the position of its caller
+	for i, frame := range result.Frames {
+		if !frame.Symbolicated || i+1 >= len(result.Frames) {
+			continue
+		}
+		caller := result.Frames[i+1]
+		if !caller.Symbolicated {
+			continue
+		}
+		position := sourceMap.OriginalPositionFor(caller.Line, caller.Column-1)
+		if position != nil && position.Name != "" {
+			frame.OriginalFunctionName = position.Name
+		}
+	}
+
+	return result
+}
+
+// sourceMapMatchesFile reports whether a frame's file is the generated file of
+// the source map. Maps without a "file" field are assumed to match every frame.
+func sourceMapMatchesFile(sourceMap *SourceMap, file string) bool {
+	if sourceMap.File == "" {
+		return true
+	}
+	file = strings.SplitN(strings.SplitN(file, "?", 2)[0], "#", 2)[0]
+	return path.Base(file) == path.Base(sourceMap.File)
+}
+
+// String renders the stack trace in V8 format, using original positions
+// for every symbolicated frame
+func (s *SymbolicatedStack) String() string {
+	lines := make([]string, 0, len(s.Frames)+1)
+	if s.Message != "" {
+		lines = append(lines, s.Message)
+	}
+	for _, frame := range s.Frames {
+		lines = append(lines, "    "+frame.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// String renders the frame in V8 format ("at fn (file:line:column)")
+func (f *StackFrame) String() string {
+	name := f.GetDisplayFunctionName()
+	if !f.HasLocation() {
+		if name == "" {
+			return strings.TrimSpace(f.Raw)
+		}
+		if f.File != "" {
+			return fmt.Sprintf("at %s (%s)", name, f.File)
+		}
+		return "at " + name
+	}
+
+	file, line, column := f.File, f.Line, f.Column
+	if f.Symbolicated {
+		file, line, column = f.OriginalFile, f.OriginalLine, f.OriginalColumn
+	}
+	if name == "" {
+		return fmt.Sprintf("at %s:%d:%d", file, line, column)
+	}
+	return fmt.Sprintf("at %s (%s:%d:%d)", name, file, line, column)
+}
+
+// Getter methods for SymbolicatedStack
+
+func (s *SymbolicatedStack) GetMessage() string  { return s.Message }
+func (s *SymbolicatedStack) GetFramesCount() int { return len(s.Frames) }
+func (s *SymbolicatedStack) GetTrace() string    { return s.String() }
+
+func (s *SymbolicatedStack) GetFrame(index int) *StackFrame {
+	if index >= 0 && index < len(s.Frames) {
+		return s.Frames[index]
+	}
+	return nil
+}
+
+// Getter methods for StackFrame
+
+func (f *StackFrame) GetRaw() string                  { return f.Raw }
+func (f *StackFrame) GetFunctionName() string         { return f.FunctionName }
+func (f *StackFrame) GetFile() string                 { return f.File }
+func (f *StackFrame) GetLine() int                    { return f.Line }
+func (f *StackFrame) GetColumn() int                  { return f.Column }
+func (f *StackFrame) HasLocation() bool               { return f.Line > 0 }
+func (f *StackFrame) IsSymbolicated() bool            { return f.Symbolicated }
+func (f *StackFrame) GetOriginalFile() string         { return f.OriginalFile }
+func (f *StackFrame) GetOriginalLine() int            { return f.OriginalLine }
+func (f *StackFrame) GetOriginalColumn() int          { return f.OriginalColumn }
+func (f *StackFrame) GetOriginalFunctionName() string { return f.OriginalFunctionName }
+
+// GetDisplayFunctionName returns the original function name when known,
+// falling back to the name reported by the engine
+func (f *StackFrame) GetDisplayFunctionName() string {
+	if f.OriginalFunctionName != "" {
+		return f.OriginalFunctionName
+	}
+	return f.FunctionName
+}
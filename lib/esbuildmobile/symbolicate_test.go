@@ -0,0 +1,98 @@
+package esbuildmobile
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestSymbolicate(t *testing.T) {
+	input := "function explode(value) {\n  throw new Error(value)\n}\nfunction run() {\n  explode('boom')\n}\nrun()\n"
+
+	options := NewBuildOptions()
+	options.ConfigureBundle(true)
+	options.ConfigureMinifyWhitespace(true)
+	options.ConfigureMinifyIdentifiers(true)
+	options.ConfigureFormat(api.FormatIIFE)
+	options.ConfigureSourcemap(api.SourceMapExternal)
+	options.ConfigureSourcefile("app.js")
+	options.ConfigureOutfile("/bundle/out.js")
+
+	result, err := BuildWithResult(input, options)
+	if err != nil {
+		t.Fatal("build failed: ", err)
+	}
+	sourceMap := result.GetOutputFileByPath("/bundle/out.js.map")
+	if sourceMap == nil {
+		t.Fatal("missing source map output")
+	}
+	parsed, err := ParseSourceMap(sourceMap.GetText())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Build a stack from the generated positions of the throw and the call site
+	throwSite := parsed.GeneratedPositionFor("app.js", 2, 2)
+	callSite := parsed.GeneratedPositionFor("app.js", 5, 2)
+	if throwSite == nil || callSite == nil {
+		t.Fatal("expected generated positions")
+	}
+
+	v8Stack := fmt.Sprintf("Error: boom\n    at o (http://localhost/out.js:%d:%d)\n    at n (http://localhost/out.js:%d:%d)\n    at native",
+		throwSite.Line, throwSite.Column+1, callSite.Line, callSite.Column+1)
+	jscStack := fmt.Sprintf("o@http://localhost/out.js:%d:%d\nn@http://localhost/out.js:%d:%d\n[native code]",
+		throwSite.Line, throwSite.Column+1, callSite.Line, callSite.Column+1)
+	hermesStack := fmt.Sprintf("Error: boom\n    at o (address at http://localhost/out.js:%d:%d)\n    at n (address at http://localhost/out.js:%d:%d)\n    at apply (native)",
+		throwSite.Line, throwSite.Column+1, callSite.Line, callSite.Column+1)
+
+	for _, stack := range []string{v8Stack, jscStack, hermesStack} {
+		symbolicated, err := Symbolicate(stack, sourceMap.GetText())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if symbolicated.GetFramesCount() != 3 {
+			t.Fatalf("expected 3 frames, got %d", symbolicated.GetFramesCount())
+		}
+
+		first := symbolicated.GetFrame(0)
+		if !first.IsSymbolicated() || first.GetOriginalFile() != "app.js" || first.GetOriginalLine() != 2 || first.GetOriginalColumn() != 3 {
+			t.Fatalf("unexpected first frame: %+v", first)
+		}
+		if first.GetDisplayFunctionName() != "explode" {
+			t.Fatalf("expected explode, got %q", first.GetDisplayFunctionName())
+		}
+		if symbolicated.GetFrame(2).IsSymbolicated() {
+			t.Fatal("native frame should not be symbolicated")
+		}
+		if !strings.Contains(symbolicated.GetTrace(), "at explode (app.js:2:3)") {
+			t.Fatalf("unexpected trace:\n%s", symbolicated.GetTrace())
+		}
+	}
+
+	// Frames without a function name, such as top-level code
+	v8Unnamed := fmt.Sprintf("    at o (http://localhost/out.js:%d:%d)\n    at http://localhost/out.js:%d:%d",
+		throwSite.Line, throwSite.Column+1, callSite.Line, callSite.Column+1)
+	jscUnnamed := fmt.Sprintf("o@http://localhost/out.js:%d:%d\n@http://localhost/out.js:%d:%d\n[native code]",
+		throwSite.Line, throwSite.Column+1, callSite.Line, callSite.Column+1)
+	for _, stack := range []string{v8Unnamed, jscUnnamed} {
+		symbolicated, err := Symbolicate(stack, sourceMap.GetText())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if symbolicated.GetMessage() != "" || symbolicated.GetFramesCount() < 2 {
+			t.Fatalf("expected every line to be a frame, got %+v", symbolicated)
+		}
+		unnamed := symbolicated.GetFrame(1)
+		if unnamed.GetFunctionName() != "" || !unnamed.IsSymbolicated() || unnamed.GetOriginalLine() != 5 {
+			t.Fatalf("unexpected unnamed frame: %+v", unnamed)
+		}
+		if symbolicated.GetFrame(0).GetDisplayFunctionName() != "explode" {
+			t.Fatalf("expected the caller to name the first frame, got %q", symbolicated.GetFrame(0).GetDisplayFunctionName())
+		}
+		if !strings.Contains(symbolicated.GetTrace(), "\n    at app.js:5:3") {
+			t.Fatalf("unexpected trace:\n%s", symbolicated.GetTrace())
+		}
+	}
+}
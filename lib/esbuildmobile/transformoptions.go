@@ -1,6 +1,10 @@
 package esbuildmobile
 
-import "github.com/evanw/esbuild/pkg/api"
+import (
+	"encoding/json"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
 
 type TransformOptions struct {
 	Color       api.StderrColor
@@ -52,6 +56,11 @@ type TransformOptions struct {
 
 	Sourcefile string
 	Loader     api.Loader
+
+	CancelHandle  *CancelHandle `json:"-"` // Stops waiting for the transform when its Cancel method is called
+	TimeoutMillis int           `json:"-"` // Stops waiting for the transform after this many milliseconds, 0 for no timeout
+
+	Cache *Cache `json:"-"` // Reuses the result of an earlier transform with the same input and options
 }
 
 // NewTransformOptions creates a new TransformOptions with default values
@@ -149,6 +158,21 @@ func (t *TransformOptions) ConfigureFooter(footer string) {
 	t.Footer = footer
 }
 
+// Configure cancellation handle
+func (t *TransformOptions) ConfigureCancelHandle(handle *CancelHandle) {
+	t.CancelHandle = handle
+}
+
+// Configure timeout in milliseconds
+func (t *TransformOptions) ConfigureTimeoutMillis(timeout int) {
+	t.TimeoutMillis = timeout
+}
+
+// Configure a cache for transform results
+func (t *TransformOptions) ConfigureCache(cache *Cache) {
+	t.Cache = cache
+}
+
 // Configure line limit
 func (t *TransformOptions) ConfigureLineLimit(limit int) {
 	t.LineLimit = limit
@@ -686,3 +710,13 @@ func (t *TransformOptions) ClearDefine()      { t.Define = nil }
 func (t *TransformOptions) ClearLogOverride() { t.LogOverride = nil }
 func (t *TransformOptions) ClearSupported()   { t.Supported = nil }
 func (t *TransformOptions) ClearMangleCache() { t.MangleCache = nil }
+
+// ConfigureMangleCacheJSON sets the mangle cache from JSON, such as the
+// value returned by TransformResult.GetMangleCacheJSON
+func (t *TransformOptions) ConfigureMangleCacheJSON(cache string) error {
+	if cache == "" {
+		t.MangleCache = nil
+		return nil
+	}
+	return json.Unmarshal([]byte(cache), &t.MangleCache)
+}
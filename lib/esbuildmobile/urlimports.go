@@ -0,0 +1,461 @@
+package esbuildmobile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// maxRedirects is the number of redirects followed before a fetch fails
+const maxRedirects = 10
+
+// Fetcher downloads the contents of a URL. Implement it in the host app
+// (for example with URLSession) or use NewHTTPFetcher.
+type Fetcher interface {
+	Fetch(url string) (*FetchResponse, error)
+}
+
+// FetchResponse is the response of a Fetcher. Fetchers can either follow
+// redirects themselves and set URL to the final URL, or return the 3xx
+// status with Location and let the build follow it.
+type FetchResponse struct {
+	URL         string // final URL, "" for the requested URL
+	StatusCode  int    // 0 is treated as 200
+	ContentType string
+	Location    string // redirect target when StatusCode is 3xx
+	Contents    []byte
+}
+
+// NewFetchResponse creates a successful response
+func NewFetchResponse(contents []byte, contentType string) *FetchResponse {
+	return &FetchResponse{
+		StatusCode:  http.StatusOK,
+		ContentType: contentType,
+		Contents:    contents,
+	}
+}
+
+// NewRedirectResponse creates a redirect to another URL
+func NewRedirectResponse(statusCode int, location string) *FetchResponse {
+	return &FetchResponse{
+		StatusCode: statusCode,
+		Location:   location,
+	}
+}
+
+// Configuration methods for FetchResponse
+
+func (r *FetchResponse) ConfigureURL(url string)       { r.URL = url }
+func (r *FetchResponse) ConfigureStatusCode(code int)  { r.StatusCode = code }
+func (r *FetchResponse) ConfigureLocation(loc string)  { r.Location = loc }
+func (r *FetchResponse) ConfigureContentType(t string) { r.ContentType = t }
+
+// Getter methods for FetchResponse
+
+func (r *FetchResponse) GetURL() string         { return r.URL }
+func (r *FetchResponse) GetStatusCode() int     { return r.StatusCode }
+func (r *FetchResponse) GetContentType() string { return r.ContentType }
+func (r *FetchResponse) GetLocation() string    { return r.Location }
+func (r *FetchResponse) GetContents() []byte    { return r.Contents }
+
+// HTTPFetcher is a Fetcher using Go's HTTP client. Redirects are returned
+// to the build instead of being followed by the client.
+type HTTPFetcher struct {
+	client  *http.Client
+	headers map[string]string
+}
+
+// NewHTTPFetcher creates a fetcher with a 30 second timeout per request
+func NewHTTPFetcher() *HTTPFetcher {
+	return &HTTPFetcher{
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		headers: make(map[string]string),
+	}
+}
+
+// AddHeader sends a header with every request, such as a User-Agent
+func (f *HTTPFetcher) AddHeader(name string, value string) {
+	f.headers[name] = value
+}
+
+// ConfigureTimeoutMillis sets the timeout of each request, 0 for no timeout
+func (f *HTTPFetcher) ConfigureTimeoutMillis(timeout int) {
+	f.client.Timeout = time.Duration(timeout) * time.Millisecond
+}
+
+func (f *HTTPFetcher) Fetch(rawURL string) (*FetchResponse, error) {
+	request, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range f.headers {
+		request.Header.Set(name, value)
+	}
+
+	response, err := f.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	contents, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &FetchResponse{
+		StatusCode:  response.StatusCode,
+		ContentType: response.Header.Get("Content-Type"),
+		Location:    response.Header.Get("Location"),
+		Contents:    contents,
+	}, nil
+}
+
+// SwiftFetcher is a concrete implementation that can be used from Swift
+type SwiftFetcher struct {
+	handler func(string) (*FetchResponse, error)
+}
+
+// NewSwiftFetcher creates a fetcher that executes a handler
+func NewSwiftFetcher() *SwiftFetcher {
+	return &SwiftFetcher{}
+}
+
+// SetHandler sets the handler function
+func (f *SwiftFetcher) SetHandler(handler func(string) (*FetchResponse, error)) {
+	f.handler = handler
+}
+
+// Fetch implements the Fetcher interface
+func (f *SwiftFetcher) Fetch(rawURL string) (*FetchResponse, error) {
+	if f.handler == nil {
+		return nil, errors.New("no fetch handler was set")
+	}
+	return f.handler(rawURL)
+}
+
+// URLImports resolves and loads imports such as "https://esm.sh/preact"
+// through a Fetcher. Attach it with BuildOptions.ConfigureURLImports.
+//
+// Relative and absolute imports inside fetched modules are resolved against
+// the final URL of the module, after redirects. With a content cache, every
+// URL is fetched once and later builds work offline. Builds with URL imports
+// are only stored in a result Cache when a frozen Lockfile pins every URL.
+type URLImports struct {
+	fetcher  Fetcher
+	cache    CacheBackend
+	lockfile *Lockfile
+
+	mutex   sync.Mutex
+	fetches map[string]*urlFetch
+}
+
+// remoteModule is a fetched module, as stored in the content cache
+type remoteModule struct {
+	URL         string
+	ContentType string
+	Contents    []byte
+}
+
+// urlFetch makes concurrent imports of the same URL share one request
+type urlFetch struct {
+	once   sync.Once
+	module *remoteModule
+	err    error
+}
+
+// NewURLImports creates URL imports backed by a fetcher
+func NewURLImports(fetcher Fetcher) *URLImports {
+	return &URLImports{
+		fetcher: fetcher,
+		fetches: make(map[string]*urlFetch),
+	}
+}
+
+// ConfigureCache keeps fetched modules in a backend, such as a
+// DiskCacheBackend, so that they are not fetched again by later builds
+func (u *URLImports) ConfigureCache(cache CacheBackend) {
+	u.cache = cache
+}
+
+// ConfigureLockfile verifies every fetched or cached module against a
+// lockfile, recording URLs that are not listed yet unless it is frozen
+func (u *URLImports) ConfigureLockfile(lockfile *Lockfile) {
+	u.lockfile = lockfile
+}
+
+// Reset forgets the modules fetched by earlier builds. Modules in the
+// content cache are kept.
+func (u *URLImports) Reset() {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.fetches = make(map[string]*urlFetch)
+}
+
+// fetch returns the module for a URL from memory, the content cache or the fetcher
+func (u *URLImports) fetch(rawURL string) (*remoteModule, error) {
+	u.mutex.Lock()
+	fetch, ok := u.fetches[rawURL]
+	if !ok {
+		fetch = &urlFetch{}
+		u.fetches[rawURL] = fetch
+	}
+	u.mutex.Unlock()
+
+	fetch.once.Do(func() {
+		if fetch.module = u.cached(rawURL); fetch.module == nil {
+			fetch.module, fetch.err = u.fetchRemote(rawURL)
+		}
+	})
+
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if fetch.err != nil {
+		// Failed fetches are retried by the next build
+		if u.fetches[rawURL] == fetch {
+			delete(u.fetches, rawURL)
+		}
+	} else if _, ok := u.fetches[fetch.module.URL]; !ok {
+		// Modules are loaded by their final URL after redirects
+		done := &urlFetch{module: fetch.module}
+		done.once.Do(func() {})
+		u.fetches[fetch.module.URL] = done
+	}
+	return fetch.module, fetch.err
+}
+
+// fetchRemote calls the fetcher, following redirects
+func (u *URLImports) fetchRemote(rawURL string) (*remoteModule, error) {
+	current := rawURL
+	for redirects := 0; ; redirects++ {
+		response, err := u.fetcher.Fetch(current)
+		if err != nil {
+			return nil, fmt.Errorf("Could not fetch %s: %v", current, err)
+		}
+		if response == nil {
+			return nil, fmt.Errorf("Could not fetch %s: the fetcher returned no response", current)
+		}
+
+		status := response.StatusCode
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if status >= 300 && status < 400 {
+			if redirects >= maxRedirects {
+				return nil, fmt.Errorf("Could not fetch %s: too many redirects", rawURL)
+			}
+			if current, err = resolveURL(current, response.Location); err != nil || response.Location == "" {
+				return nil, fmt.Errorf("Could not fetch %s: invalid redirect to %q", rawURL, response.Location)
+			}
+			continue
+		}
+		if status < 200 || status >= 300 {
+			return nil, fmt.Errorf("Could not fetch %s: the server responded with status %d", current, status)
+		}
+
+		module := &remoteModule{
+			URL:         current,
+			ContentType: response.ContentType,
+			Contents:    response.Contents,
+		}
+		if response.URL != "" {
+			module.URL = response.URL
+		}
+		u.store(rawURL, module)
+		return module, nil
+	}
+}
+
+func urlCacheKey(rawURL string) string {
+	sum := sha256.Sum256([]byte("url-import\x00" + rawURL))
+	return hex.EncodeToString(sum[:])
+}
+
+func (u *URLImports) cached(rawURL string) *remoteModule {
+	if u.cache == nil {
+		return nil
+	}
+	data := u.cache.Get(urlCacheKey(rawURL))
+	if data == nil {
+		return nil
+	}
+	module := &remoteModule{}
+	if json.Unmarshal(data, module) != nil || module.URL == "" {
+		return nil
+	}
+	return module
+}
+
+// store saves a module under the requested URL and its final URL, ignoring cache errors
+func (u *URLImports) store(rawURL string, module *remoteModule) {
+	if u.cache == nil {
+		return
+	}
+	data, err := json.Marshal(module)
+	if err != nil {
+		return
+	}
+	u.cache.Put(urlCacheKey(rawURL), data)
+	if module.URL != rawURL {
+		u.cache.Put(urlCacheKey(module.URL), data)
+	}
+}
+
+// resolveURL resolves a reference against a base URL
+func resolveURL(base string, reference string) (string, error) {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	referenceURL, err := url.Parse(reference)
+	if err != nil {
+		return "", err
+	}
+	return baseURL.ResolveReference(referenceURL).String(), nil
+}
+
+// isURLImport reports whether an import is an http:// or https:// URL
+func isURLImport(importPath string) bool {
+	return strings.HasPrefix(importPath, "http://") || strings.HasPrefix(importPath, "https://")
+}
+
+// urlNamespace returns NamespaceHTTP or NamespaceHTTPS for a URL
+func urlNamespace(rawURL string) string {
+	if strings.HasPrefix(rawURL, "http://") {
+		return NamespaceHTTP
+	}
+	return NamespaceHTTPS
+}
+
+// contentTypeLoaders maps the media types served by CDNs to loaders
+var contentTypeLoaders = map[string]api.Loader{
+	"application/javascript":   api.LoaderJS,
+	"text/javascript":          api.LoaderJS,
+	"application/x-javascript": api.LoaderJS,
+	"application/typescript":   api.LoaderTS,
+	"text/typescript":          api.LoaderTS,
+	"text/jsx":                 api.LoaderJSX,
+	"text/tsx":                 api.LoaderTSX,
+	"text/css":                 api.LoaderCSS,
+	"application/json":         api.LoaderJSON,
+}
+
+// loaderForModule picks a loader from the Loader option by extension, then
+// from the content type, defaulting to JavaScript
+func loaderForModule(module *remoteModule, loaders map[string]api.Loader) api.Loader {
+	urlPath := module.URL
+	if parsed, err := url.Parse(module.URL); err == nil {
+		urlPath = parsed.Path
+	}
+	if loader, ok := loaders[path.Ext(urlPath)]; ok {
+		return loader
+	}
+	if mediaType, _, err := mime.ParseMediaType(module.ContentType); err == nil {
+		if loader, ok := contentTypeLoaders[mediaType]; ok {
+			return loader
+		}
+	}
+	if loader, ok := loaderForPath(urlPath, nil); ok {
+		return loader
+	}
+	return api.LoaderJS
+}
+
+// plugin serves URL imports to a build
+func (u *URLImports) plugin(options *BuildOptions, withCacheKey bool) *Plugin {
+	plugin := NewPlugin("esbuildmobile-url-imports")
+	if withCacheKey && u.lockfile != nil && u.lockfile.IsFrozen() {
+		plugin.SetCacheKey(u.lockfile.hash())
+	}
+
+	resolveCallback := &urlResolveCallback{imports: u}
+	plugin.OnResolve(CreateFilterForPath("^https?://"), resolveCallback)
+	plugin.OnResolve(CreateFilterForPathAndNamespace(FilterAllFiles, NamespaceHTTP), resolveCallback)
+	plugin.OnResolve(CreateFilterForPathAndNamespace(FilterAllFiles, NamespaceHTTPS), resolveCallback)
+
+	loadCallback := &urlLoadCallback{imports: u, loaders: options.Loader}
+	for _, namespace := range []string{NamespaceHTTP, NamespaceHTTPS} {
+		loadOptions := CreateFilterForNamespace(namespace)
+		loadOptions.SetLoadFilter(FilterAllFiles)
+		plugin.OnLoad(loadOptions, loadCallback)
+	}
+	return plugin
+}
+
+type urlResolveCallback struct {
+	imports *URLImports
+}
+
+func (c *urlResolveCallback) Call(args *OnResolveArgs) *OnResolveResult {
+	result := NewOnResolveResult()
+
+	rawURL := args.Path
+	if !isURLImport(rawURL) {
+		// Imports inside fetched modules are relative to the module's URL
+		if !isPathImport(rawURL) || !isURLImport(args.Importer) {
+			return result
+		}
+		resolved, err := resolveURL(args.Importer, rawURL)
+		if err != nil {
+			result.AddResolveError(NewMessage(fmt.Sprintf("Invalid import %q: %v", rawURL, err)))
+			return result
+		}
+		rawURL = resolved
+	}
+
+	importer := args.Importer
+	if importer == "" {
+		importer = "the entry point"
+	}
+	// A frozen lockfile rejects unlisted URLs before anything is fetched
+	var err error
+	if c.imports.lockfile != nil {
+		err = c.imports.lockfile.checkListed(rawURL, importer)
+	}
+	var module *remoteModule
+	if err == nil {
+		module, err = c.imports.fetch(rawURL)
+	}
+	if err == nil && c.imports.lockfile != nil {
+		err = c.imports.lockfile.verify(rawURL, importer, module)
+	}
+	if err != nil {
+		result.AddResolveError(NewMessage(err.Error()))
+		return result
+	}
+	result.Path = module.URL
+	result.Namespace = urlNamespace(module.URL)
+	return result
+}
+
+type urlLoadCallback struct {
+	imports *URLImports
+	loaders map[string]api.Loader
+}
+
+func (c *urlLoadCallback) Call(args *OnLoadArgs) *OnLoadResult {
+	module, err := c.imports.fetch(args.Path)
+	if err != nil {
+		result := NewOnLoadResult()
+		result.AddLoadError(NewMessage(err.Error()))
+		return result
+	}
+	result := CreateLoadResult(string(module.Contents), loaderForModule(module, c.loaders))
+	result.SetLoadLoaderFromImportAttributes(args)
+	return result
+}
@@ -0,0 +1,81 @@
+package esbuildmobile
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func newModuleServer(requests *atomic.Int32) *httptest.Server {
+	modules := map[string]string{
+		"/preact@10.0.0/index.js": `export { h } from "./h.js"; export { util } from "/shared/util.js"`,
+		"/preact@10.0.0/h.js":     `export const h = "h from cdn"`,
+		"/shared/util.js":         `export const util = "util from cdn"`,
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path == "/preact" {
+			http.Redirect(w, r, "/preact@10.0.0/index.js", http.StatusFound)
+			return
+		}
+		contents, ok := modules[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+		w.Write([]byte(contents))
+	}))
+}
+
+func TestURLImports(t *testing.T) {
+	var requests atomic.Int32
+	server := newModuleServer(&requests)
+	cacheDir := filepath.Join(t.TempDir(), "modules")
+
+	build := func(input string) (string, error) {
+		cache, err := NewDiskCacheBackend(cacheDir, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		imports := NewURLImports(NewHTTPFetcher())
+		imports.ConfigureCache(cache)
+
+		options := NewBuildOptions()
+		options.ConfigureBundle(true)
+		options.ConfigureURLImports(imports)
+		return Build(input, options)
+	}
+
+	input := `import { h, util } from "` + server.URL + `/preact"; console.log(h, util)`
+	code, err := build(input)
+	if err != nil {
+		t.Fatal("build failed: ", err)
+	}
+	if !strings.Contains(code, `"h from cdn"`) || !strings.Contains(code, `"util from cdn"`) {
+		t.Fatalf("unexpected output:\n%s", code)
+	}
+	if !strings.Contains(code, server.URL+"/preact@10.0.0/h.js") {
+		t.Fatalf("expected relative imports to resolve against the final URL:\n%s", code)
+	}
+	if requests.Load() != 4 {
+		t.Fatalf("expected 4 requests including the redirect, got %d", requests.Load())
+	}
+
+	// Later builds are served from the content cache, even offline
+	server.Close()
+	if code, err = build(input); err != nil {
+		t.Fatal("offline build failed: ", err)
+	}
+	if !strings.Contains(code, `"h from cdn"`) {
+		t.Fatalf("unexpected offline output:\n%s", code)
+	}
+
+	_, err = build(`import "` + server.URL + `/missing.js"`)
+	if err == nil || !strings.Contains(err.Error(), "Could not fetch") {
+		t.Fatalf("expected a fetch error, got %v", err)
+	}
+}
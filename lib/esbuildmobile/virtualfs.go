@@ -0,0 +1,330 @@
+package esbuildmobile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// NamespaceVirtualFS is the namespace of every file loaded from a VirtualFS
+const NamespaceVirtualFS = "virtual-fs"
+
+// defaultResolveExtensions matches esbuild's default for ResolveExtensions
+var defaultResolveExtensions = []string{".tsx", ".ts", ".jsx", ".js", ".css", ".json"}
+
+// defaultLoaders matches the loaders esbuild uses for files on disk
+var defaultLoaders = map[string]api.Loader{
+	".js":         api.LoaderJS,
+	".mjs":        api.LoaderJS,
+	".cjs":        api.LoaderJS,
+	".jsx":        api.LoaderJSX,
+	".ts":         api.LoaderTS,
+	".mts":        api.LoaderTS,
+	".cts":        api.LoaderTS,
+	".tsx":        api.LoaderTSX,
+	".css":        api.LoaderCSS,
+	".module.css": api.LoaderLocalCSS,
+	".json":       api.LoaderJSON,
+	".txt":        api.LoaderText,
+}
+
+// VirtualFS is an in-memory directory tree that builds can bundle from
+// instead of the disk. Attach it with BuildOptions.ConfigureVirtualFS, then
+// use absolute paths such as "/src/index.js" as entry points.
+//
+// Paths always use "/" and are relative to the root of the file system.
+// Files can be added and removed between builds of a BuildContext.
+type VirtualFS struct {
+	mutex sync.RWMutex
+	files map[string][]byte
+	dirs  map[string]bool
+}
+
+// NewVirtualFS creates an empty file system
+func NewVirtualFS() *VirtualFS {
+	return &VirtualFS{
+		files: make(map[string][]byte),
+		dirs:  map[string]bool{"/": true},
+	}
+}
+
+// cleanVirtualPath makes a path absolute and removes "." and ".." segments
+func cleanVirtualPath(p string) string {
+	return path.Clean("/" + strings.ReplaceAll(p, "\\", "/"))
+}
+
+// joinVirtualPath resolves an import path against a directory. Absolute
+// paths start at the root instead.
+func joinVirtualPath(dir string, p string) string {
+	if strings.HasPrefix(p, "/") {
+		return cleanVirtualPath(p)
+	}
+	return cleanVirtualPath(path.Join(dir, p))
+}
+
+// AddFile adds or replaces a file, creating its parent directories
+func (fs *VirtualFS) AddFile(filePath string, contents []byte) {
+	filePath = cleanVirtualPath(filePath)
+
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	fs.files[filePath] = append(make([]byte, 0, len(contents)), contents...)
+	fs.addDirectory(path.Dir(filePath))
+}
+
+// RemoveFile removes a file, returning false if it did not exist.
+// Directories are kept even when they become empty.
+func (fs *VirtualFS) RemoveFile(filePath string) bool {
+	filePath = cleanVirtualPath(filePath)
+
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	if _, ok := fs.files[filePath]; !ok {
+		return false
+	}
+	delete(fs.files, filePath)
+	return true
+}
+
+// AddDirectory creates a directory and its parents
+func (fs *VirtualFS) AddDirectory(dirPath string) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	fs.addDirectory(cleanVirtualPath(dirPath))
+}
+
+func (fs *VirtualFS) addDirectory(dirPath string) {
+	for !fs.dirs[dirPath] {
+		fs.dirs[dirPath] = true
+		dirPath = path.Dir(dirPath)
+	}
+}
+
+// Clear removes every file and directory
+func (fs *VirtualFS) Clear() {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	fs.files = make(map[string][]byte)
+	fs.dirs = map[string]bool{"/": true}
+}
+
+// removeTree removes a directory with every file and directory inside it
+func (fs *VirtualFS) removeTree(dirPath string) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	for filePath := range fs.files {
+		if strings.HasPrefix(filePath, dirPath+"/") {
+			delete(fs.files, filePath)
+		}
+	}
+	for dir := range fs.dirs {
+		if dir == dirPath || strings.HasPrefix(dir, dirPath+"/") {
+			delete(fs.dirs, dir)
+		}
+	}
+}
+
+// Getter methods for VirtualFS
+
+func (fs *VirtualFS) HasFile(filePath string) bool {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+	_, ok := fs.files[cleanVirtualPath(filePath)]
+	return ok
+}
+
+func (fs *VirtualFS) HasDirectory(dirPath string) bool {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+	return fs.dirs[cleanVirtualPath(dirPath)]
+}
+
+// GetFile returns the contents of a file, or nil if it does not exist
+func (fs *VirtualFS) GetFile(filePath string) []byte {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+	return fs.files[cleanVirtualPath(filePath)]
+}
+
+func (fs *VirtualFS) GetFilesCount() int {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+	return len(fs.files)
+}
+
+// GetFilePath returns the path of a file, with files sorted by path
+func (fs *VirtualFS) GetFilePath(index int) string {
+	paths := fs.filePaths()
+	if index >= 0 && index < len(paths) {
+		return paths[index]
+	}
+	return ""
+}
+
+func (fs *VirtualFS) filePaths() []string {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+	paths := make([]string, 0, len(fs.files))
+	for filePath := range fs.files {
+		paths = append(paths, filePath)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// hash returns a hash of every path and its contents, used as the cache key of builds
+func (fs *VirtualFS) hash() string {
+	paths := fs.filePaths()
+
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+	hasher := sha256.New()
+	for _, filePath := range paths {
+		fmt.Fprintf(hasher, "%s\x00%d\x00", filePath, len(fs.files[filePath]))
+		hasher.Write(fs.files[filePath])
+	}
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// resolveFile finds the file for a path the same way esbuild does on disk:
+// the exact path, then each extension, then an index file in the directory
+func (fs *VirtualFS) resolveFile(filePath string, extensions []string) (string, bool) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	if _, ok := fs.files[filePath]; ok {
+		return filePath, true
+	}
+	for _, extension := range extensions {
+		if _, ok := fs.files[filePath+extension]; ok {
+			return filePath + extension, true
+		}
+	}
+	if fs.dirs[filePath] {
+		index := path.Join(filePath, "index")
+		for _, extension := range extensions {
+			if _, ok := fs.files[index+extension]; ok {
+				return index + extension, true
+			}
+		}
+	}
+	return "", false
+}
+
+// loaderForPath picks a loader from the Loader option, falling back to the
+// default loaders as esbuild does, so ".css" in the option wins over the
+// default for ".module.css". Within each, longer extensions win.
+func loaderForPath(filePath string, loaders map[string]api.Loader) (api.Loader, bool) {
+	if loader, ok := loaderForExtension(filePath, loaders); ok {
+		return loader, true
+	}
+	return loaderForExtension(filePath, defaultLoaders)
+}
+
+// loaderForExtension looks up the extensions of a path, longest first
+func loaderForExtension(filePath string, loaders map[string]api.Loader) (api.Loader, bool) {
+	base := path.Base(filePath)
+	for dot := strings.IndexByte(base, '.'); dot >= 0; {
+		if loader, ok := loaders[base[dot:]]; ok {
+			return loader, true
+		}
+		next := strings.IndexByte(base[dot+1:], '.')
+		if next < 0 {
+			break
+		}
+		dot += next + 1
+	}
+	return api.LoaderNone, false
+}
+
+// isPathImport reports whether an import is a relative or absolute path
+// rather than a package name
+func isPathImport(importPath string) bool {
+	return strings.HasPrefix(importPath, "./") || strings.HasPrefix(importPath, "../") ||
+		strings.HasPrefix(importPath, "/") || importPath == "." || importPath == ".."
+}
+
+// plugin serves the file system to a build. Relative imports from files in
+// the file system must resolve inside it. Entry points resolve from the
+// root, and other imports, such as those of files on disk, resolve against
+// their ResolveDir, or the root when it is empty as for stdin. Both fall
+// back to esbuild when the path does not exist.
+func (fs *VirtualFS) plugin(options *BuildOptions, withCacheKey bool) *Plugin {
+	plugin := NewPlugin("esbuildmobile-virtual-fs")
+	if withCacheKey {
+		plugin.SetCacheKey(fs.hash())
+	}
+
+	extensions := options.ResolveExtensions
+	if len(extensions) == 0 {
+		extensions = defaultResolveExtensions
+	}
+	plugin.OnResolve(CreateFilterForPath(FilterAllFiles), &virtualFSResolveCallback{fs: fs, extensions: extensions})
+
+	loadOptions := CreateFilterForNamespace(NamespaceVirtualFS)
+	loadOptions.SetLoadFilter(FilterAllFiles)
+	plugin.OnLoad(loadOptions, &virtualFSLoadCallback{fs: fs, loaders: options.Loader})
+	return plugin
+}
+
+type virtualFSResolveCallback struct {
+	fs         *VirtualFS
+	extensions []string
+}
+
+func (c *virtualFSResolveCallback) Call(args *OnResolveArgs) *OnResolveResult {
+	result := NewOnResolveResult()
+	fromVirtualFS := args.Namespace == NamespaceVirtualFS
+	if !isPathImport(args.Path) && args.Kind != ResolveEntryPoint {
+		return result
+	}
+	// Files of other plugins resolve their own relative imports
+	if !fromVirtualFS && args.Namespace != NamespaceFile && args.Namespace != "" {
+		return result
+	}
+
+	dir := args.ResolveDir
+	if dir == "" || args.Kind == ResolveEntryPoint {
+		dir = "/"
+	}
+	resolved, ok := c.fs.resolveFile(joinVirtualPath(dir, args.Path), c.extensions)
+	if !ok {
+		if fromVirtualFS {
+			result.AddResolveError(NewMessage(fmt.Sprintf("Could not resolve %q in the virtual file system", args.Path)))
+		}
+		return result
+	}
+	result.Path = resolved
+	result.Namespace = NamespaceVirtualFS
+	return result
+}
+
+type virtualFSLoadCallback struct {
+	fs      *VirtualFS
+	loaders map[string]api.Loader
+}
+
+func (c *virtualFSLoadCallback) Call(args *OnLoadArgs) *OnLoadResult {
+	contents := c.fs.GetFile(args.Path)
+	if contents == nil {
+		result := NewOnLoadResult()
+		result.AddLoadError(NewMessage(fmt.Sprintf("File %q was removed from the virtual file system", args.Path)))
+		return result
+	}
+	loader, ok := loaderForImportAttributes(args.With)
+	if !ok {
+		loader, ok = loaderForPath(args.Path, c.loaders)
+	}
+	if !ok {
+		result := NewOnLoadResult()
+		result.AddLoadError(NewMessage(fmt.Sprintf("No loader is configured for %q files: %s", path.Ext(args.Path), args.Path)))
+		return result
+	}
+	return CreateLoadResultWithResolveDir(string(contents), loader, path.Dir(args.Path))
+}
@@ -0,0 +1,107 @@
+package esbuildmobile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestVirtualFSBundle(t *testing.T) {
+	fs := NewVirtualFS()
+	fs.AddFile("/src/index.ts", []byte(`import { greet } from "./lib"; import data from "../data.json"; import text from "./notes.md"; console.log(greet(data.name), text)`))
+	fs.AddFile("/src/lib/index.js", []byte(`export { greet } from "./greet"`))
+	fs.AddFile("/src/lib/greet.tsx", []byte(`export const greet = (name: string) => <b>{name}</b>`))
+	fs.AddFile("/data.json", []byte(`{"name": "virtual"}`))
+	fs.AddFile("/src/notes.md", []byte(`# notes`))
+
+	options := NewBuildOptions()
+	options.ConfigureBundle(true)
+	options.ConfigureVirtualFS(fs)
+	options.AddEntryPoint("/src/index.ts")
+	options.Loader[".md"] = api.LoaderText
+
+	code, err := Build("", options)
+	if err != nil {
+		t.Fatal("build failed: ", err)
+	}
+	for _, expected := range []string{`React.createElement("b"`, `name: "virtual"`, `# notes`} {
+		if !strings.Contains(code, expected) {
+			t.Fatalf("expected %q in output:\n%s", expected, code)
+		}
+	}
+
+	// Relative imports never escape to the disk
+	fs.RemoveFile("/src/lib/greet.tsx")
+	_, err = Build("", options)
+	if err == nil || !strings.Contains(err.Error(), `Could not resolve "./greet"`) {
+		t.Fatalf("expected resolve error after removing a file, got %v", err)
+	}
+}
+
+func TestVirtualFSStdinAndLoaders(t *testing.T) {
+	fs := NewVirtualFS()
+	fs.AddDirectory("/empty")
+	fs.AddFile("components/button.jsx", []byte(`export default () => <button />`))
+	fs.AddFile("/image.png", []byte{0x89, 'P', 'N', 'G'})
+
+	if !fs.HasDirectory("/empty") || !fs.HasDirectory("/components") || fs.GetFilePath(0) != "/components/button.jsx" {
+		t.Fatal("unexpected virtual file system contents")
+	}
+
+	options := NewBuildOptions()
+	options.ConfigureBundle(true)
+	options.ConfigureVirtualFS(fs)
+
+	code, err := Build(`import Button from "./components/button"; console.log(Button)`, options)
+	if err != nil {
+		t.Fatal("build failed: ", err)
+	}
+	if !strings.Contains(code, `React.createElement("button"`) {
+		t.Fatalf("unexpected output:\n%s", code)
+	}
+
+	_, err = Build(`import "./image.png"`, options)
+	if err == nil || !strings.Contains(err.Error(), `No loader is configured for ".png" files`) {
+		t.Fatalf("expected missing loader error, got %v", err)
+	}
+
+	// Loaders from the options win over the defaults, as in esbuild
+	fs.AddFile("/button.module.css", []byte(`.button { color: red }`))
+	options.ConfigureLoaderEntry(".css", api.LoaderText)
+	code, err = Build(`import styles from "./button.module.css"; console.log(styles)`, options)
+	if err != nil {
+		t.Fatal("build failed: ", err)
+	}
+	if !strings.Contains(code, `".button { color: red }"`) {
+		t.Fatalf("expected the .css loader from the options for a .module.css file:\n%s", code)
+	}
+}
+
+func TestVirtualFSWithDiskFiles(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "app.js"), []byte(`import { util } from "./util.js"; import { shared } from "/shared.js"; console.log(util, shared)`), 0o644)
+	os.WriteFile(filepath.Join(dir, "util.js"), []byte(`export const util = "disk util"`), 0o644)
+
+	fs := NewVirtualFS()
+	fs.AddFile("/util.js", []byte(`export const util = "virtual util"`))
+	fs.AddFile("/shared.js", []byte(`export const shared = "virtual shared"`))
+
+	options := NewBuildOptions()
+	options.ConfigureBundle(true)
+	options.ConfigureVirtualFS(fs)
+	options.AddEntryPoint(filepath.Join(dir, "app.js"))
+
+	code, err := Build("", options)
+	if err != nil {
+		t.Fatal("build failed: ", err)
+	}
+	if !strings.Contains(code, `"disk util"`) || strings.Contains(code, `"virtual util"`) {
+		t.Fatalf("expected the disk file next to the importer to win:\n%s", code)
+	}
+	if !strings.Contains(code, `"virtual shared"`) {
+		t.Fatalf("expected absolute imports missing on disk to come from the virtual file system:\n%s", code)
+	}
+}